@@ -3,16 +3,26 @@ package main
 import (
 	"context"
 	sqlite2 "github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/config"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/chunk_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/container_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/file_server_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/import_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_split_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/key_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/presign_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/transfer_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/container_usecase"
 	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/file_server_usecase"
 	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/path_usecase"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/presign_usecase"
 	"github.com/PavelKhripkov/object_storage/internal/handler/api/http/v1"
 	"github.com/PavelKhripkov/object_storage/pkg/client/sqlite"
+	"github.com/PavelKhripkov/object_storage/pkg/content_mapper"
+	"github.com/PavelKhripkov/object_storage/pkg/disk_cache"
+	"github.com/PavelKhripkov/object_storage/pkg/throttle"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
 	"net/http"
@@ -27,9 +37,13 @@ func main() {
 
 	l.Info("Starting app")
 
+	cfg, err := config.NewConfig()
+	if err != nil {
+		l.WithError(err).Fatal("Couldn't read configuration.")
+	}
+
 	router := httprouter.New()
-	// TODO get from config
-	db, err := sqlite.NewClient(context.TODO(), "", "", "./object_storage.db")
+	db, err := sqlite.NewClient(context.TODO(), "", "", cfg.DBPath)
 	if err != nil {
 		l.WithError(err).Fatal("Couldn't connect to database.")
 	}
@@ -39,9 +53,21 @@ func main() {
 		}
 	}()
 
+	// chunk cache
+	var chunkCache *disk_cache.Cache
+	if cfg.ChunkCacheDir != "" {
+		chunkCache, err = disk_cache.NewCache(cfg.ChunkCacheDir, cfg.ChunkCacheSize, logger.WithField("component", "ChunkCache"))
+		if err != nil {
+			l.WithError(err).Fatal("Couldn't open chunk cache.")
+		}
+	}
+
+	// Shared by all outgoing streams: downloads and chunk transfers.
+	egress := throttle.NewBucket(cfg.EgressRate)
+
 	// file server
 	fileServerStorage := sqlite2.NewFileServerStorage(db, logger)
-	fileServerService := file_server_service.NewFileServerService(fileServerStorage, logger)
+	fileServerService := file_server_service.NewFileServerService(fileServerStorage, chunkCache, egress, logger)
 	fileServerUsecase := file_server_usecase.NewFileServerUsecase(fileServerService, logger)
 	fileServerHandler := v1.NewFileServerHandler(fileServerUsecase, logger)
 
@@ -49,27 +75,64 @@ func main() {
 	chunkStorage := sqlite2.NewChunkStorage(db, logger)
 	chunkService := chunk_service.NewChunkService(chunkStorage, logger)
 
+	// container
+	containerStorage := sqlite2.NewContainerStorage(db, logger)
+	containerService := container_service.NewContainerService(containerStorage, logger)
+
+	// key
+	keyService, err := key_service.NewKeyService(cfg.MasterKeys, logger)
+	if err != nil {
+		l.WithError(err).Fatal("Couldn't load master keys.")
+	}
+	if !keyService.Enabled() {
+		l.Warn("No master key configured, items are stored unencrypted.")
+	}
+
+	// transfer
+	transferService := transfer_service.NewTransferService(cfg.MaxTransfers, cfg.MaxTransfersPerServer, logger)
+
+	// import
+	importService := import_service.NewImportService(cfg.ImportRoot, v1.MaxFileSize, cfg.ImportTimeout, cfg.ImportAllowedNets, logger)
+
 	// item
+	readAhead := content_mapper.ReadAhead{Parts: cfg.ReadAhead, BufferSize: cfg.ReadAheadBuffer}
+	retry := content_mapper.Retry{Retries: cfg.DownloadRetries, Delay: cfg.DownloadRetryDelay}
 	splitFileService := item_split_service.NewFileSplitService(logger)
 	itemStorage := sqlite2.NewItemStorage(db, logger)
 	itemService := item_service.NewItemService(itemStorage, logger)
-	itemUsecase := item_usecase.NewItemUsecase(itemService, chunkService, fileServerService, splitFileService, logger)
-	itemHandler := v1.NewItemHandler(itemUsecase, logger)
+	itemUsecase := item_usecase.NewItemUsecase(itemService, chunkService, containerService, fileServerService, splitFileService, keyService, transferService, importService, readAhead, retry, egress, logger)
 
-	// container
-	containerStorage := sqlite2.NewContainerStorage(db, logger)
-	containerService := container_service.NewContainerService(containerStorage, logger)
-	containerUsecase := container_usecase.NewContainerUsecase(containerService, logger)
+	// presign
+	presignService := presign_service.NewPresignService(cfg.SigningKey, logger)
+	presignUsecase := presign_usecase.NewPresignUsecase(itemService, containerService, presignService, logger)
+	presignHandler := v1.NewPresignHandler(presignUsecase, logger)
+
+	go itemUsecase.RunDeletions(context.Background())
+
+	itemHandler := v1.NewItemHandler(itemUsecase, presignUsecase, cfg.WaitTimeout, logger)
+
+	containerUsecase := container_usecase.NewContainerUsecase(containerService, itemUsecase, cfg.TrashRetention, logger)
 	containerHandler := v1.NewContainerHandler(containerUsecase, logger)
 
+	go containerUsecase.RunPurge(context.Background())
+
+	// path
+	pathUsecase := path_usecase.NewPathUsecase(containerService, itemService, itemUsecase, logger)
+	pathHandler := v1.NewPathHandler(pathUsecase, itemUsecase, cfg.WaitTimeout, logger)
+
+	// admin
+	adminHandler := v1.NewAdminHandler(itemUsecase, logger)
+
 	l.Info("Registering handlers")
 	itemHandler.Register(router)
 	fileServerHandler.Register(router)
 	containerHandler.Register(router)
+	adminHandler.Register(router)
+	presignHandler.Register(router)
+	pathHandler.Register(router)
 
-	// TODO get from config
-	l.Info("Listening on port 11111")
-	if err := http.ListenAndServe(":11111", router); err != nil {
+	l.Infof("Listening on %s", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, router); err != nil {
 		l.Fatal(err)
 	}
 }
@@ -10,10 +10,30 @@ create table chunk
             references file_server,
     file_path      TEXT    not null,
     size           INTEGER not null,
+    stored_size    INTEGER not null,
     created        INTEGER,
     modified       INTEGER
 );
 
+create index chunk_file_server_id_file_path_index
+    on chunk (file_server_id, file_path);
+
+------------------------------------------
+
+create table chunk_deletion
+(
+    id             TEXT    not null
+        constraint chunk_deletion_pk
+            primary key,
+    file_server_id TEXT    not null,
+    file_path      TEXT    not null,
+    stored_size    INTEGER not null,
+    attempts       INTEGER default 0 not null,
+    next_attempt   INTEGER not null,
+    last_error     TEXT default '' not null,
+    created        INTEGER
+);
+
 ------------------------------------------
 
 create table container
@@ -26,12 +46,18 @@ create table container
     parent_id   TEXT not null
         constraint container_container_id_fk
             references container,
+    compression TEXT default 'none' not null,
+    encryption  TEXT default '' not null,
+    cache_control TEXT default '' not null,
+    rate_limit  INTEGER default 0 not null,
+    versioning  INTEGER default 0 not null,
+    trashed     INTEGER default 0 not null,
     created     INTEGER,
     modified    INTEGER
 );
 
 create unique index container_parent_id_name_uindex
-    on container (parent_id, name);
+    on container (parent_id, name, trashed);
 
 ------------------------------------------
 
@@ -65,6 +91,23 @@ create table item
     chunk_count  INTEGER,
     status       TEXT,
     size         INTEGER,
+    stored_size  INTEGER default 0 not null,
+    hash         TEXT default '' not null,
+    content_type TEXT default '' not null,
+    compression  TEXT default 'none' not null,
+    encryption   TEXT default 'none' not null,
+    key_id       TEXT default '' not null,
+    data_key     BLOB,
+    metadata     TEXT default '{}' not null,
+    version      INTEGER default 0 not null,
+    delete_marker INTEGER default 0 not null,
+    trashed      INTEGER default 0 not null,
     created      INTEGER,
     modified     INTEGER
-);
\ No newline at end of file
+);
+
+create index item_container_id_name_index
+    on item (container_id, name);
+
+create index item_trashed_index
+    on item (trashed);
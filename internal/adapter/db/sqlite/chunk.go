@@ -23,7 +23,7 @@ func NewChunkStorage(db *sql.DB, l *log.Logger) *ChunkStorage {
 func (s *ChunkStorage) Get(ctx context.Context, id string) (chunk_model.Chunk, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, item_id, position, file_server_id, file_path, size, created, modified FROM chunk WHERE id = ? LIMIT 1",
+		"SELECT id, item_id, position, file_server_id, file_path, size, stored_size, created, modified FROM chunk WHERE id = ? LIMIT 1",
 	)
 	if err != nil {
 		return chunk_model.Chunk{}, err
@@ -39,7 +39,7 @@ func (s *ChunkStorage) Get(ctx context.Context, id string) (chunk_model.Chunk, e
 	var created, modified int64
 
 	err = stmt.QueryRowContext(ctx, id).
-		Scan(&entity.ID, &entity.ItemID, &entity.Position, &entity.FileServerID, &entity.FilePath, &entity.Size, &created, &modified)
+		Scan(&entity.ID, &entity.ItemID, &entity.Position, &entity.FileServerID, &entity.FilePath, &entity.Size, &entity.StoredSize, &created, &modified)
 	switch {
 	case err == sql.ErrNoRows:
 		return chunk_model.Chunk{}, ErrNotFound
@@ -56,7 +56,7 @@ func (s *ChunkStorage) Get(ctx context.Context, id string) (chunk_model.Chunk, e
 func (s *ChunkStorage) Create(ctx context.Context, chunk chunk_model.Chunk) error {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"INSERT INTO chunk (id, item_id, position, file_server_id, file_path, size, created, modified) values (?, ?, ?, ?, ?, ?, ?, ?)",
+		"INSERT INTO chunk (id, item_id, position, file_server_id, file_path, size, stored_size, created, modified) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	)
 	if err != nil {
 		return err
@@ -67,7 +67,7 @@ func (s *ChunkStorage) Create(ctx context.Context, chunk chunk_model.Chunk) erro
 		}
 	}()
 
-	_, err = stmt.ExecContext(ctx, chunk.ID, chunk.ItemID, chunk.Position, chunk.FileServerID, chunk.FilePath, chunk.Size, chunk.Created.UnixMilli(), chunk.Modified.UnixMilli())
+	_, err = stmt.ExecContext(ctx, chunk.ID, chunk.ItemID, chunk.Position, chunk.FileServerID, chunk.FilePath, chunk.Size, chunk.StoredSize, chunk.Created.UnixMilli(), chunk.Modified.UnixMilli())
 	if err != nil {
 		return err
 	}
@@ -75,14 +75,171 @@ func (s *ChunkStorage) Create(ctx context.Context, chunk chunk_model.Chunk) erro
 	return nil
 }
 
+// Delete deletes chunk model and queues its file to be removed from file server, in a single transaction.
 func (s *ChunkStorage) Delete(ctx context.Context, chunk chunk_model.Chunk) error {
-	return nil
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = queueChunkDeletions(ctx, tx, "id = ?", chunk.ID); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// QueueDeletion queues file having no chunk model, e.g. one stored by failed upload, to be removed from file server.
+func (s *ChunkStorage) QueueDeletion(ctx context.Context, deletion chunk_model.Deletion) error {
+	stmt, err := s.db.PrepareContext(
+		ctx,
+		"INSERT INTO chunk_deletion (id, file_server_id, file_path, stored_size, next_attempt, created) values (?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	_, err = stmt.ExecContext(ctx, deletion.ChunkID, deletion.FileServerID, deletion.FilePath, deletion.StoredSize, deletion.NextAttempt.UnixMilli(), deletion.Created.UnixMilli())
+
+	return err
+}
+
+// ListDeletions returns chunk deletions to be attempted before the time, earliest first.
+func (s *ChunkStorage) ListDeletions(ctx context.Context, before time.Time, limit int) ([]chunk_model.Deletion, error) {
+	stmt, err := s.db.PrepareContext(
+		ctx,
+		"SELECT id, file_server_id, file_path, stored_size, attempts, next_attempt, last_error, created FROM chunk_deletion WHERE next_attempt <= ? ORDER BY next_attempt LIMIT ?",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	rows, err := stmt.QueryContext(ctx, before.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]chunk_model.Deletion, 0)
+
+	for rows.Next() {
+		entity := chunk_model.Deletion{}
+		var nextAttempt, created int64
+		if err = rows.Scan(&entity.ChunkID, &entity.FileServerID, &entity.FilePath, &entity.StoredSize, &entity.Attempts, &nextAttempt, &entity.LastError, &created); err != nil {
+			return nil, err
+		}
+
+		entity.NextAttempt = time.UnixMilli(nextAttempt)
+		entity.Created = time.UnixMilli(created)
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// CompleteDeletion removes deletion from the queue and decreases used space of the file server, in a single transaction.
+func (s *ChunkStorage) CompleteDeletion(ctx context.Context, deletion chunk_model.Deletion) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM chunk_deletion WHERE id = ?", deletion.ChunkID)
+
+	var affected int64
+	if err == nil {
+		affected, err = res.RowsAffected()
+	}
+
+	// Deletion completed already shouldn't decrease used space twice.
+	if err == nil && affected > 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE file_server SET used_space = max(used_space - ?, 0) WHERE id = ?",
+			deletion.StoredSize,
+			deletion.FileServerID,
+		)
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RetryDeletion records failed attempt of deletion and schedules the next one.
+func (s *ChunkStorage) RetryDeletion(ctx context.Context, id string, nextAttempt time.Time, lastError string) error {
+	stmt, err := s.db.PrepareContext(ctx, "UPDATE chunk_deletion SET attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	_, err = stmt.ExecContext(ctx, nextAttempt.UnixMilli(), lastError, id)
+
+	return err
+}
+
+// queueChunkDeletions deletes chunks matching the condition within transaction. Chunk files are reference counted
+// by chunks: copies of items share them. So files are queued to be removed only if no other chunk refers to them.
+func queueChunkDeletions(ctx context.Context, tx *sql.Tx, where string, args ...any) error {
+	now := time.Now().UnixMilli()
+
+	// Condition is repeated in the subquery, where it applies to other chunks referring to the same file.
+	queryArgs := append([]any{now, now}, args...)
+	queryArgs = append(queryArgs, args...)
+
+	_, err := tx.ExecContext(
+		ctx,
+		"INSERT OR IGNORE INTO chunk_deletion (id, file_server_id, file_path, stored_size, next_attempt, created) "+
+			"SELECT c.id, c.file_server_id, c.file_path, c.stored_size, ?, ? FROM chunk c WHERE "+where+
+			" AND NOT EXISTS (SELECT 1 FROM chunk WHERE file_server_id = c.file_server_id AND file_path = c.file_path AND NOT ("+where+"))"+
+			" GROUP BY c.file_server_id, c.file_path",
+		queryArgs...,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM chunk WHERE "+where, args...)
+
+	return err
 }
 
 func (s *ChunkStorage) GetItemChunks(ctx context.Context, id string) ([]chunk_model.Chunk, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, item_id, position, file_server_id, file_path, size, created, modified FROM chunk WHERE item_id = ? ORDER BY position",
+		"SELECT id, item_id, position, file_server_id, file_path, size, stored_size, created, modified FROM chunk WHERE item_id = ? ORDER BY position",
 	)
 	if err != nil {
 		return nil, err
@@ -100,7 +257,7 @@ func (s *ChunkStorage) GetItemChunks(ctx context.Context, id string) ([]chunk_mo
 	for rows.Next() {
 		entity := chunk_model.Chunk{}
 		var created, modified int64
-		if err = rows.Scan(&entity.ID, &entity.ItemID, &entity.Position, &entity.FileServerID, &entity.FilePath, &entity.Size, &created, &modified); err != nil {
+		if err = rows.Scan(&entity.ID, &entity.ItemID, &entity.Position, &entity.FileServerID, &entity.FilePath, &entity.Size, &entity.StoredSize, &created, &modified); err != nil {
 			return nil, err
 		}
 		entity.Created = time.UnixMilli(created)
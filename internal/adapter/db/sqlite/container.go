@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"strings"
 	"time"
 )
 
@@ -23,7 +26,7 @@ func NewContainerStorage(db *sql.DB, l *log.Logger) *ContainerStorage {
 func (s ContainerStorage) Get(ctx context.Context, id string) (container_model.Container, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, name, description, parent_id, created, modified FROM container WHERE id = ? LIMIT 1",
+		"SELECT id, name, description, parent_id, compression, encryption, cache_control, rate_limit, versioning, trashed, created, modified FROM container WHERE id = ? LIMIT 1",
 	)
 	if err != nil {
 		return container_model.Container{}, err
@@ -36,10 +39,10 @@ func (s ContainerStorage) Get(ctx context.Context, id string) (container_model.C
 
 	entity := container_model.Container{}
 
-	var created, modified int64
+	var created, modified, trashed int64
 
 	err = stmt.QueryRowContext(ctx, id).
-		Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &created, &modified)
+		Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &entity.Compression, &entity.Encryption, &entity.CacheControl, &entity.RateLimit, &entity.Versioning, &trashed, &created, &modified)
 	switch {
 	case err == sql.ErrNoRows:
 		return container_model.Container{}, ErrNotFound
@@ -49,6 +52,42 @@ func (s ContainerStorage) Get(ctx context.Context, id string) (container_model.C
 
 	entity.Created = time.UnixMilli(created)
 	entity.Modified = time.UnixMilli(modified)
+	entity.Trashed = trashedTime(trashed)
+
+	return entity, nil
+}
+
+// GetByName returns child container of the parent by name, out of trash. Root containers have empty parent ID.
+func (s ContainerStorage) GetByName(ctx context.Context, parentID, name string) (container_model.Container, error) {
+	stmt, err := s.db.PrepareContext(
+		ctx,
+		"SELECT id, name, description, parent_id, compression, encryption, cache_control, rate_limit, versioning, trashed, created, modified FROM container WHERE parent_id = ? AND name = ? AND trashed = 0 LIMIT 1",
+	)
+	if err != nil {
+		return container_model.Container{}, err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	entity := container_model.Container{}
+
+	var created, modified, trashed int64
+
+	err = stmt.QueryRowContext(ctx, parentID, name).
+		Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &entity.Compression, &entity.Encryption, &entity.CacheControl, &entity.RateLimit, &entity.Versioning, &trashed, &created, &modified)
+	switch {
+	case err == sql.ErrNoRows:
+		return container_model.Container{}, ErrNotFound
+	case err != nil:
+		return container_model.Container{}, err
+	}
+
+	entity.Created = time.UnixMilli(created)
+	entity.Modified = time.UnixMilli(modified)
+	entity.Trashed = trashedTime(trashed)
 
 	return entity, nil
 }
@@ -56,7 +95,7 @@ func (s ContainerStorage) Get(ctx context.Context, id string) (container_model.C
 func (s ContainerStorage) List(ctx context.Context) ([]container_model.Container, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, name, description, parent_id, created, modified FROM container",
+		"SELECT id, name, description, parent_id, compression, encryption, cache_control, rate_limit, versioning, trashed, created, modified FROM container",
 	)
 	if err != nil {
 		return nil, err
@@ -73,13 +112,14 @@ func (s ContainerStorage) List(ctx context.Context) ([]container_model.Container
 
 	for rows.Next() {
 		entity := container_model.Container{}
-		var created, modified int64
-		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &created, &modified); err != nil {
+		var created, modified, trashed int64
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &entity.Compression, &entity.Encryption, &entity.CacheControl, &entity.RateLimit, &entity.Versioning, &trashed, &created, &modified); err != nil {
 			return nil, err
 		}
 
 		entity.Created = time.UnixMilli(created)
 		entity.Modified = time.UnixMilli(modified)
+		entity.Trashed = trashedTime(trashed)
 
 		res = append(res, entity)
 	}
@@ -91,10 +131,81 @@ func (s ContainerStorage) List(ctx context.Context) ([]container_model.Container
 	return res, nil
 }
 
+// ListPage returns a page of containers and total number of containers matching the query filters.
+// Either containers in trash or other ones are listed. One container more than the limit is returned if there is the next page.
+func (s ContainerStorage) ListPage(ctx context.Context, trashed bool, q page_model.Query) ([]container_model.Container, int, error) {
+	conditions := []string{"trashed = 0"}
+	var args []interface{}
+
+	if trashed {
+		conditions[0] = "trashed != 0"
+	}
+
+	if q.Prefix != "" {
+		condition, conditionArgs := prefixCondition(q.Prefix)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	var total int
+
+	err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM container WHERE "+strings.Join(conditions, " AND "), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	condition, conditionArgs, tail, err := pageClauses(q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id, name, description, parent_id, compression, encryption, cache_control, rate_limit, versioning, trashed, created, modified FROM container WHERE "+
+			strings.Join(conditions, " AND ")+tail,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]container_model.Container, 0)
+
+	for rows.Next() {
+		entity := container_model.Container{}
+		var created, modified, trashed int64
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Description, &entity.ParentID, &entity.Compression, &entity.Encryption, &entity.CacheControl, &entity.RateLimit, &entity.Versioning, &trashed, &created, &modified); err != nil {
+			return nil, 0, err
+		}
+
+		entity.Created = time.UnixMilli(created)
+		entity.Modified = time.UnixMilli(modified)
+		entity.Trashed = trashedTime(trashed)
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
 func (s ContainerStorage) Create(ctx context.Context, container container_model.Container) error {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"INSERT INTO container (id, name, description, parent_id, created, modified) VALUES (?, ?, ?, ?, ?, ?)",
+		"INSERT INTO container (id, name, description, parent_id, compression, encryption, cache_control, rate_limit, versioning, created, modified) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	)
 	if err != nil {
 		return err
@@ -106,7 +217,7 @@ func (s ContainerStorage) Create(ctx context.Context, container container_model.
 	}()
 
 	_, err = stmt.ExecContext(
-		ctx, container.ID, container.Name, container.Description, container.ParentID, container.Created.UnixMilli(), container.Modified.UnixMilli(),
+		ctx, container.ID, container.Name, container.Description, container.ParentID, container.Compression, container.Encryption, container.CacheControl, container.RateLimit, container.Versioning, container.Created.UnixMilli(), container.Modified.UnixMilli(),
 	)
 	if err != nil {
 		return err
@@ -115,7 +226,259 @@ func (s ContainerStorage) Create(ctx context.Context, container container_model.
 	return nil
 }
 
-func (s ContainerStorage) Delete(ctx context.Context, id string) error {
-	// TODO implement.
+// Move sets parent and name of the container. Returns ErrCycle if the parent is the container itself
+// or its descendant. Parent chain is checked after update within the same transaction, so concurrent moves
+// can't make a cycle either.
+func (s ContainerStorage) Move(ctx context.Context, id, parentID, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE container SET parent_id = ?, name = ?, modified = ? WHERE id = ?", parentID, name, time.Now().UnixMilli(), id)
+
+	var affected int64
+	if err == nil {
+		affected, err = res.RowsAffected()
+	}
+
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
+
+	var cycle bool
+
+	if err == nil {
+		err = tx.QueryRowContext(
+			ctx,
+			"WITH RECURSIVE ancestor(id) AS (SELECT ? UNION SELECT c.parent_id FROM container c JOIN ancestor a ON c.id = a.id WHERE c.parent_id != '') "+
+				"SELECT EXISTS (SELECT 1 FROM ancestor WHERE id = ?)",
+			parentID, id,
+		).Scan(&cycle)
+	}
+
+	if err == nil && cycle {
+		err = ErrCycle
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EnableVersioning turns on versioning of container items.
+func (s ContainerStorage) EnableVersioning(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE container SET versioning = 1, modified = ? WHERE id = ?", time.Now().UnixMilli(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
+
+// subtreeCTE selects IDs of the container and its descendants.
+const subtreeCTE = "WITH RECURSIVE subtree(id) AS (SELECT ? UNION SELECT c.id FROM container c JOIN subtree t ON c.parent_id = t.id) "
+
+// Trash moves container with its child containers and items to trash at the time, in a single transaction.
+// Entities moved to trash before are kept as they are. Unless recursive is set, only container without items
+// and child containers out of trash is moved, ErrNotEmpty is returned otherwise. Recursive move fails with ErrBusy
+// if an item of the subtree is still being stored.
+func (s ContainerStorage) Trash(ctx context.Context, id string, at time.Time, recursive bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var res sql.Result
+
+	if recursive {
+		res, err = tx.ExecContext(ctx, "UPDATE container SET trashed = ?, modified = ? WHERE id = ? AND trashed = 0", at.UnixMilli(), time.Now().UnixMilli(), id)
+	} else {
+		res, err = tx.ExecContext(
+			ctx,
+			"UPDATE container SET trashed = ?, modified = ? WHERE id = ? AND trashed = 0 "+
+				"AND NOT EXISTS (SELECT 1 FROM item WHERE container_id = ? AND trashed = 0) AND NOT EXISTS (SELECT 1 FROM container WHERE parent_id = ? AND trashed = 0)",
+			at.UnixMilli(), time.Now().UnixMilli(), id, id, id,
+		)
+	}
+
+	if err == nil {
+		err = checkAffected(res)
+	}
+
+	if errors.Is(err, ErrNotFound) && !recursive {
+		var exists bool
+		if err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM container WHERE id = ? AND trashed = 0)", id).Scan(&exists); err == nil {
+			err = ErrNotFound
+			if exists {
+				err = ErrNotEmpty
+			}
+		}
+	}
+
+	// Checked after the container is updated, so the transaction holds write lock and no item is added meanwhile.
+	if err == nil && recursive {
+		var busy bool
+		err = tx.QueryRowContext(
+			ctx,
+			subtreeCTE+"SELECT EXISTS (SELECT 1 FROM item WHERE container_id IN subtree AND trashed = 0 AND status = 'pending')",
+			id,
+		).Scan(&busy)
+
+		if err == nil && busy {
+			err = ErrBusy
+		}
+	}
+
+	if err == nil && recursive {
+		_, err = tx.ExecContext(ctx, subtreeCTE+"UPDATE container SET trashed = ? WHERE id IN subtree AND trashed = 0", id, at.UnixMilli())
+	}
+
+	if err == nil && recursive {
+		_, err = tx.ExecContext(ctx, subtreeCTE+"UPDATE item SET trashed = ? WHERE container_id IN subtree AND trashed = 0", id, at.UnixMilli())
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes container out of trash with child containers and items moved to trash together with it.
+// Returns ErrNotFound if there is no such container in trash.
+func (s ContainerStorage) Restore(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var trashed int64
+
+	err = tx.QueryRowContext(ctx, "SELECT trashed FROM container WHERE id = ? AND trashed != 0", id).Scan(&trashed)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+
+	if err == nil {
+		_, err = tx.ExecContext(ctx, subtreeCTE+"UPDATE item SET trashed = 0 WHERE container_id IN subtree AND trashed = ?", id, trashed)
+	}
+
+	if err == nil {
+		_, err = tx.ExecContext(ctx, subtreeCTE+"UPDATE container SET trashed = 0 WHERE id IN subtree AND trashed = ?", id, trashed)
+	}
+
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE container SET modified = ? WHERE id = ?", time.Now().UnixMilli(), id)
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListTrashed returns containers moved to trash before the time. Only ID and parent ID fields are filled.
+func (s ContainerStorage) ListTrashed(ctx context.Context, before time.Time) ([]container_model.Container, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, parent_id FROM container WHERE trashed != 0 AND trashed < ?", before.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]container_model.Container, 0)
+
+	for rows.Next() {
+		entity := container_model.Container{}
+		if err = rows.Scan(&entity.ID, &entity.ParentID); err != nil {
+			return nil, err
+		}
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Delete deletes container, unless it has items or child containers. Check and deletion are done in a single statement,
+// so items stored concurrently aren't left without container.
+func (s ContainerStorage) Delete(ctx context.Context, id string) error {
+	return s.deleteWhere(ctx, id, "id = ?", id)
+}
+
+// DeleteTrashed deletes container like Delete, but only if it's moved to trash before the time.
+// Returns ErrNotFound if there is no such container, e.g. it's restored meanwhile.
+func (s ContainerStorage) DeleteTrashed(ctx context.Context, id string, before time.Time) error {
+	return s.deleteWhere(ctx, id, "id = ? AND trashed != 0 AND trashed < ?", id, before.UnixMilli())
+}
+
+// deleteWhere deletes container matching the condition, unless it has items or child containers.
+func (s ContainerStorage) deleteWhere(ctx context.Context, id string, where string, args ...any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM container WHERE "+where+")", args...).Scan(&exists)
+	if err == nil && !exists {
+		err = ErrNotFound
+	}
+
+	var res sql.Result
+	if err == nil {
+		res, err = tx.ExecContext(
+			ctx,
+			"DELETE FROM container WHERE "+where+" AND NOT EXISTS (SELECT 1 FROM item WHERE container_id = ?) AND NOT EXISTS (SELECT 1 FROM container WHERE parent_id = ?)",
+			append(args, id, id)...,
+		)
+	}
+
+	var affected int64
+	if err == nil {
+		affected, err = res.RowsAffected()
+	}
+
+	if err == nil && affected == 0 {
+		err = ErrNotEmpty
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -2,4 +2,9 @@ package sqlite
 
 import "github.com/pkg/errors"
 
-var ErrNotFound = errors.New("entity not found")
+var (
+	ErrNotFound = errors.New("entity not found")
+	ErrNotEmpty = errors.New("entity isn't empty")
+	ErrCycle    = errors.New("entity can't be moved into itself or its descendant")
+	ErrBusy     = errors.New("entity has content being stored")
+)
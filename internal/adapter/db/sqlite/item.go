@@ -3,8 +3,12 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	log "github.com/sirupsen/logrus"
+	"strings"
 	"time"
 )
 
@@ -23,7 +27,7 @@ func NewItemStorage(db *sql.DB, l *log.Logger) *ItemStorage {
 func (s *ItemStorage) Get(ctx context.Context, id string) (item_model.Item, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, name, size, container_id, chunk_count, status, created, modified FROM item WHERE id = ? LIMIT 1",
+		"SELECT id, name, size, stored_size, hash, content_type, container_id, chunk_count, status, compression, encryption, key_id, data_key, metadata, version, delete_marker, trashed, created, modified FROM item WHERE id = ? LIMIT 1",
 	)
 	if err != nil {
 		return item_model.Item{}, err
@@ -36,10 +40,11 @@ func (s *ItemStorage) Get(ctx context.Context, id string) (item_model.Item, erro
 
 	entity := item_model.Item{}
 
-	var created, modified int64
+	var created, modified, trashed int64
+	var metadata string
 
 	err = stmt.QueryRowContext(ctx, id).
-		Scan(&entity.ID, &entity.Name, &entity.Size, &entity.ContainerID, &entity.ChunkCount, &entity.Status, &created, &modified)
+		Scan(&entity.ID, &entity.Name, &entity.Size, &entity.StoredSize, &entity.Hash, &entity.ContentType, &entity.ContainerID, &entity.ChunkCount, &entity.Status, &entity.Compression, &entity.Encryption, &entity.KeyID, &entity.DataKey, &metadata, &entity.Version, &entity.DeleteMarker, &trashed, &created, &modified)
 	switch {
 	case err == sql.ErrNoRows:
 		return item_model.Item{}, ErrNotFound
@@ -49,6 +54,11 @@ func (s *ItemStorage) Get(ctx context.Context, id string) (item_model.Item, erro
 
 	entity.Created = time.UnixMilli(created)
 	entity.Modified = time.UnixMilli(modified)
+	entity.Trashed = trashedTime(trashed)
+
+	if entity.Metadata, err = decodeMetadata(metadata); err != nil {
+		return item_model.Item{}, err
+	}
 
 	return entity, nil
 }
@@ -56,7 +66,7 @@ func (s *ItemStorage) Get(ctx context.Context, id string) (item_model.Item, erro
 func (s *ItemStorage) List(ctx context.Context, containerID string) ([]item_model.Item, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"SELECT id, name, status, size, created, modified FROM item WHERE container_id = ?",
+		"SELECT id, name, status, size, stored_size, hash, content_type, compression, encryption, metadata, version, delete_marker, trashed, created, modified FROM item WHERE container_id = ?",
 	)
 	if err != nil {
 		return nil, err
@@ -68,18 +78,32 @@ func (s *ItemStorage) List(ctx context.Context, containerID string) ([]item_mode
 	}()
 
 	rows, err := stmt.QueryContext(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
 
 	res := make([]item_model.Item, 0)
 
 	for rows.Next() {
 		entity := item_model.Item{}
-		var created, modified int64
-		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Status, &entity.Size, &created, &modified); err != nil {
+		var created, modified, trashed int64
+		var metadata string
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Status, &entity.Size, &entity.StoredSize, &entity.Hash, &entity.ContentType, &entity.Compression, &entity.Encryption, &metadata, &entity.Version, &entity.DeleteMarker, &trashed, &created, &modified); err != nil {
 			return nil, err
 		}
 
 		entity.Created = time.UnixMilli(created)
 		entity.Modified = time.UnixMilli(modified)
+		entity.Trashed = trashedTime(trashed)
+
+		if entity.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, err
+		}
 
 		res = append(res, entity)
 	}
@@ -91,13 +115,15 @@ func (s *ItemStorage) List(ctx context.Context, containerID string) ([]item_mode
 	return res, nil
 }
 
-func (s *ItemStorage) Create(ctx context.Context, item item_model.Item) error {
+// ListByName returns items of the container with the name, the latest ones first. Versions are ordered by number.
+// Items in trash are left out.
+func (s *ItemStorage) ListByName(ctx context.Context, containerID, name string) ([]item_model.Item, error) {
 	stmt, err := s.db.PrepareContext(
 		ctx,
-		"INSERT INTO item (id, name, container_id, size, chunk_count, status, created, modified) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		"SELECT id, name, size, stored_size, hash, content_type, container_id, chunk_count, status, compression, encryption, key_id, data_key, metadata, version, delete_marker, trashed, created, modified FROM item WHERE container_id = ? AND name = ? AND trashed = 0 ORDER BY version DESC, created DESC, id DESC",
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err := stmt.Close(); err != nil {
@@ -105,18 +131,250 @@ func (s *ItemStorage) Create(ctx context.Context, item item_model.Item) error {
 		}
 	}()
 
-	_, err = stmt.ExecContext(
-		ctx, item.ID, item.Name, item.ContainerID, item.Size, item.ChunkCount, item.Status, item.Created.UnixMilli(), item.Modified.UnixMilli(),
+	rows, err := stmt.QueryContext(ctx, containerID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]item_model.Item, 0)
+
+	for rows.Next() {
+		entity := item_model.Item{}
+		var created, modified, trashed int64
+		var metadata string
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.Size, &entity.StoredSize, &entity.Hash, &entity.ContentType, &entity.ContainerID, &entity.ChunkCount, &entity.Status, &entity.Compression, &entity.Encryption, &entity.KeyID, &entity.DataKey, &metadata, &entity.Version, &entity.DeleteMarker, &trashed, &created, &modified); err != nil {
+			return nil, err
+		}
+
+		entity.Created = time.UnixMilli(created)
+		entity.Modified = time.UnixMilli(modified)
+		entity.Trashed = trashedTime(trashed)
+
+		if entity.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, err
+		}
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Conditions on other versions of the item, ones in trash are compared among themselves.
+const (
+	otherVersion = "other.container_id = item.container_id AND other.name = item.name AND (other.trashed = 0) = (item.trashed = 0)"
+	newerVersion = "(other.version > item.version OR other.version = item.version AND " +
+		"(other.created > item.created OR other.created = item.created AND other.id > item.id))"
+)
+
+// currentCondition leaves out delete markers and versions other than the current one: the latest stored version,
+// or the latest pending one if nothing is stored yet. Items of unversioned containers are listed each,
+// see item_model.Current.
+const currentCondition = "delete_marker = 0 AND (" +
+	"version = 0 AND NOT EXISTS (SELECT 1 FROM item other WHERE " + otherVersion + " AND other.version > 0) OR " +
+	"status = 'ok' AND NOT EXISTS (SELECT 1 FROM item other WHERE " + otherVersion + " AND other.status = 'ok' AND " + newerVersion + ") OR " +
+	"status = 'pending' AND NOT EXISTS (SELECT 1 FROM item other WHERE " + otherVersion +
+	" AND (other.status = 'ok' OR other.status = 'pending' AND " + newerVersion + ")))"
+
+// ListPage returns a page of container items matching the filter and total number of them.
+// Delete markers, superseded versions and items in trash aren't listed, unless trash is listed.
+// One item more than the limit is returned if there is the next page.
+func (s *ItemStorage) ListPage(ctx context.Context, containerID string, filter item_model.ListFilter, q page_model.Query) ([]item_model.Item, int, error) {
+	conditions := []string{"container_id = ?", currentCondition, "trashed = 0"}
+	args := []interface{}{containerID}
+
+	if filter.Trashed {
+		conditions[2] = "trashed != 0"
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	for k, v := range filter.Metadata {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(item.metadata) WHERE json_each.key = ? AND json_each.value = ?)")
+		args = append(args, k, v)
+	}
+
+	if q.Prefix != "" {
+		condition, conditionArgs := prefixCondition(q.Prefix)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	var total int
+
+	err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM item WHERE "+strings.Join(conditions, " AND "), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	condition, conditionArgs, tail, err := pageClauses(q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id, name, container_id, chunk_count, status, size, stored_size, hash, content_type, compression, encryption, metadata, version, delete_marker, trashed, created, modified FROM item WHERE "+
+			strings.Join(conditions, " AND ")+tail,
+		args...,
 	)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
 
-	return nil
+	res := make([]item_model.Item, 0)
+
+	for rows.Next() {
+		entity := item_model.Item{}
+		var created, modified, trashed int64
+		var metadata string
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.ContainerID, &entity.ChunkCount, &entity.Status, &entity.Size, &entity.StoredSize, &entity.Hash, &entity.ContentType, &entity.Compression, &entity.Encryption, &metadata, &entity.Version, &entity.DeleteMarker, &trashed, &created, &modified); err != nil {
+			return nil, 0, err
+		}
+
+		entity.Created = time.UnixMilli(created)
+		entity.Modified = time.UnixMilli(modified)
+		entity.Trashed = trashedTime(trashed)
+
+		if entity.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, 0, err
+		}
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+// Create creates item. Versioned item is numbered as the next version of items with the same name in the container,
+// the number is returned. Zero is returned for unversioned items.
+func (s *ItemStorage) Create(ctx context.Context, item item_model.Item, versioned bool) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := insertItem(ctx, tx, item, versioned)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return 0, err
+	}
+
+	return version, tx.Commit()
+}
+
+// CreateCopy creates item with chunks referring to files of another item's chunks, in a single transaction.
+// Versioned item is numbered as Create does. Returns ErrNotFound if a file isn't referred by any chunk anymore,
+// e.g. the original item is deleted.
+func (s *ItemStorage) CreateCopy(ctx context.Context, item item_model.Item, chunks []chunk_model.Chunk, versioned bool) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Item is inserted first, so the transaction holds write lock while checking references.
+	version, err := insertItem(ctx, tx, item, versioned)
+
+	for _, chunk := range chunks {
+		if err != nil {
+			break
+		}
+
+		var res sql.Result
+
+		res, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO chunk (id, item_id, position, file_server_id, file_path, size, stored_size, created, modified) "+
+				"SELECT ?, ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM chunk WHERE file_server_id = ? AND file_path = ?)",
+			chunk.ID, chunk.ItemID, chunk.Position, chunk.FileServerID, chunk.FilePath, chunk.Size, chunk.StoredSize, chunk.Created.UnixMilli(), chunk.Modified.UnixMilli(),
+			chunk.FileServerID, chunk.FilePath,
+		)
+
+		var affected int64
+		if err == nil {
+			affected, err = res.RowsAffected()
+		}
+
+		if err == nil && affected == 0 {
+			err = ErrNotFound
+		}
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return 0, err
+	}
+
+	return version, tx.Commit()
+}
+
+// insertItem inserts item within the transaction and returns its version number.
+// Next number is taken within the insert itself, so concurrent versions never get the same one.
+func insertItem(ctx context.Context, tx *sql.Tx, item item_model.Item, versioned bool) (int, error) {
+	metadata, err := encodeMetadata(item.Metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO item (id, name, container_id, size, stored_size, hash, content_type, chunk_count, status, compression, encryption, key_id, data_key, metadata, version, delete_marker, created, modified) "+
+			"SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CASE WHEN ? THEN coalesce(max(version), 0) + 1 ELSE 0 END, ?, ?, ? FROM item WHERE container_id = ? AND name = ?",
+		item.ID, item.Name, item.ContainerID, item.Size, item.StoredSize, item.Hash, item.ContentType, item.ChunkCount, item.Status, item.Compression, item.Encryption, item.KeyID, item.DataKey, metadata,
+		versioned, item.DeleteMarker, item.Created.UnixMilli(), item.Modified.UnixMilli(),
+		item.ContainerID, item.Name,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+
+	err = tx.QueryRowContext(ctx, "SELECT version FROM item WHERE id = ?", item.ID).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
 }
 
 func (s *ItemStorage) Update(ctx context.Context, item item_model.Item) error {
-	stmt, err := s.db.PrepareContext(ctx, "UPDATE item SET name=?, container_id=?, chunk_count=?, status=?, modified=? WHERE id = ?")
+	metadata, err := encodeMetadata(item.Metadata)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, "UPDATE item SET name=?, container_id=?, stored_size=?, hash=?, chunk_count=?, status=?, metadata=?, modified=? WHERE id = ?")
 	if err != nil {
 		return err
 	}
@@ -128,7 +386,7 @@ func (s *ItemStorage) Update(ctx context.Context, item item_model.Item) error {
 
 	modified := time.Now().UnixMilli()
 
-	_, err = stmt.ExecContext(ctx, item.Name, item.ContainerID, item.ChunkCount, item.Status, modified, item.ID)
+	_, err = stmt.ExecContext(ctx, item.Name, item.ContainerID, item.StoredSize, item.Hash, item.ChunkCount, item.Status, metadata, modified, item.ID)
 	if err != nil {
 		return err
 	}
@@ -136,6 +394,187 @@ func (s *ItemStorage) Update(ctx context.Context, item item_model.Item) error {
 	return nil
 }
 
-func (s *ItemStorage) Delete(ctx context.Context, item *item_model.Item) error {
+// UpdateDataKey replaces wrapped data key of an item.
+func (s *ItemStorage) UpdateDataKey(ctx context.Context, id, keyID string, dataKey []byte) error {
+	stmt, err := s.db.PrepareContext(ctx, "UPDATE item SET key_id=?, data_key=?, modified=? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	modified := time.Now().UnixMilli()
+
+	_, err = stmt.ExecContext(ctx, keyID, dataKey, modified, id)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// ListWrappedWithOtherKey returns encrypted items whose data keys are wrapped with a master key other than specified.
+// Only ID, key ID and data key fields are filled.
+func (s *ItemStorage) ListWrappedWithOtherKey(ctx context.Context, keyID string) ([]item_model.Item, error) {
+	stmt, err := s.db.PrepareContext(
+		ctx,
+		"SELECT id, key_id, data_key FROM item WHERE data_key IS NOT NULL AND key_id != ?",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	rows, err := stmt.QueryContext(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]item_model.Item, 0)
+
+	for rows.Next() {
+		entity := item_model.Item{}
+		if err = rows.Scan(&entity.ID, &entity.KeyID, &entity.DataKey); err != nil {
+			return nil, err
+		}
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Delete deletes item and its chunk models, queueing chunk files to be removed from file servers.
+// Everything is done in a single transaction, so chunk files are never lost track of.
+func (s *ItemStorage) Delete(ctx context.Context, id string) error {
+	return s.deleteWhere(ctx, "id = ?", id)
+}
+
+// DeleteTrashed deletes item like Delete, but only if it's moved to trash before the time.
+// Returns ErrNotFound if there is no such item, e.g. it's restored meanwhile.
+func (s *ItemStorage) DeleteTrashed(ctx context.Context, id string, before time.Time) error {
+	return s.deleteWhere(ctx, "id = ? AND trashed != 0 AND trashed < ?", id, before.UnixMilli())
+}
+
+// deleteWhere deletes item matching the condition with its chunk models, in a single transaction.
+func (s *ItemStorage) deleteWhere(ctx context.Context, where string, args ...any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = queueChunkDeletions(ctx, tx, "item_id IN (SELECT id FROM item WHERE "+where+")", args...)
+
+	var res sql.Result
+	if err == nil {
+		res, err = tx.ExecContext(ctx, "DELETE FROM item WHERE "+where, args...)
+	}
+
+	var affected int64
+	if err == nil {
+		affected, err = res.RowsAffected()
+	}
+
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.l.Error(rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Trash moves item to trash at the time. Returns ErrNotFound if there is no such item out of trash.
+func (s *ItemStorage) Trash(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE item SET trashed = ?, modified = ? WHERE id = ? AND trashed = 0", at.UnixMilli(), time.Now().UnixMilli(), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Restore takes item out of trash. Returns ErrNotFound if there is no such item in trash.
+func (s *ItemStorage) Restore(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE item SET trashed = 0, modified = ? WHERE id = ? AND trashed != 0", time.Now().UnixMilli(), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// ListTrashed returns items moved to trash before the time. Only ID, name and container ID fields are filled.
+func (s *ItemStorage) ListTrashed(ctx context.Context, before time.Time) ([]item_model.Item, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, container_id FROM item WHERE trashed != 0 AND trashed < ?", before.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	res := make([]item_model.Item, 0)
+
+	for rows.Next() {
+		entity := item_model.Item{}
+		if err = rows.Scan(&entity.ID, &entity.Name, &entity.ContainerID); err != nil {
+			return nil, err
+		}
+
+		res = append(res, entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// encodeMetadata encodes item metadata to be stored as JSON object.
+func encodeMetadata(metadata item_model.Metadata) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+
+	bytes, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func decodeMetadata(value string) (item_model.Metadata, error) {
+	var res item_model.Metadata
+
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// prefixCondition returns condition selecting entities which names start with the prefix, case-sensitive.
+func prefixCondition(prefix string) (string, []interface{}) {
+	return "instr(name, ?) = 1", []interface{}{prefix}
+}
+
+// pageClauses returns condition selecting entities after the cursor of the query, if any, and the clause ordering and
+// limiting the page. Sort fields are columns of the same name. One entity more than the limit is selected,
+// so it's known whether there is the next page.
+func pageClauses(q page_model.Query) (string, []interface{}, string, error) {
+	var column string
+
+	switch q.Sort {
+	case page_model.SortName, page_model.SortSize, page_model.SortCreated:
+		column = string(q.Sort)
+	default:
+		return "", nil, "", errors.Errorf("unknown sort: %q", q.Sort)
+	}
+
+	direction, operator := "ASC", ">"
+	if q.Order == page_model.OrderDesc {
+		direction, operator = "DESC", "<"
+	}
+
+	tail := " ORDER BY " + column + " " + direction + ", id " + direction + " LIMIT " + strconv.Itoa(q.Limit+1)
+
+	if q.After == nil {
+		return "", nil, tail, nil
+	}
+
+	var key interface{} = q.After.Key
+
+	if q.Sort != page_model.SortName {
+		number, err := strconv.ParseInt(q.After.Key, 10, 64)
+		if err != nil {
+			return "", nil, "", errors.Wrap(page_model.ErrInvalidParams, "malformed cursor")
+		}
+
+		key = number
+	}
+
+	condition := "(" + column + " " + operator + " ? OR (" + column + " = ? AND id " + operator + " ?))"
+
+	return condition, []interface{}{key, key, q.After.ID}, tail, nil
+}
@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+)
+
+// trashedTime converts trash time stored in milliseconds, zero means entity isn't in trash.
+func trashedTime(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+
+	res := time.UnixMilli(ms)
+	return &res
+}
+
+// checkAffected returns ErrNotFound if statement affected no rows.
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
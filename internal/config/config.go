@@ -0,0 +1,260 @@
+package config
+
+import (
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MasterKeySize is a required length of master keys, bytes.
+const MasterKeySize = 32
+
+// MinSigningKeySize is a minimum length of URL signing key, bytes.
+const MinSigningKeySize = 32
+
+// Config represents application settings, taken from environment variables.
+type Config struct {
+	// DBPath is a path to SQLite database file.
+	DBPath string
+	// ListenAddr is an address HTTP server listens on.
+	ListenAddr string
+	// MaxTransfers limits number of concurrent chunk transfers of all items.
+	MaxTransfers int
+	// MaxTransfersPerServer limits number of concurrent chunk transfers to a single file server.
+	MaxTransfersPerServer int
+	// WaitTimeout limits time uploads waiting for durability are waiting for by default.
+	WaitTimeout time.Duration
+	// ReadAhead is a number of chunks prefetched in parallel with the current one on download.
+	ReadAhead int
+	// ReadAheadBuffer limits number of bytes buffered for every chunk being read on download.
+	ReadAheadBuffer int
+	// DownloadRetries is a number of times a chunk is reopened after read failure during download.
+	DownloadRetries int
+	// DownloadRetryDelay is a delay before the first reopening, every next one waits longer.
+	DownloadRetryDelay time.Duration
+	// ChunkCacheDir is a directory chunks read from file servers are cached in. Cache is disabled if empty.
+	ChunkCacheDir string
+	// ChunkCacheSize limits total size of cached chunks.
+	ChunkCacheSize int64
+	// EgressRate limits total rate of downloads and chunk transfers, bytes per second. Zero means unlimited.
+	EgressRate int64
+	// ImportRoot is a directory local files can be imported from. Local import is disabled if empty.
+	ImportRoot string
+	// ImportTimeout limits time of importing an item from URL.
+	ImportTimeout time.Duration
+	// ImportAllowedNets are networks items can be imported from, even though they are loopback, private or link-local.
+	ImportAllowedNets []*net.IPNet
+	// MasterKeys are used to wrap item data keys. The first one is active, others are kept to unwrap keys until rotation.
+	MasterKeys []MasterKey
+	// SigningKey signs presigned URLs. Presigned URLs are disabled if empty.
+	SigningKey []byte
+	// TrashRetention is a time deleted containers and items are kept in trash before being purged.
+	TrashRetention time.Duration
+}
+
+// MasterKey represents a key used to wrap item data keys.
+type MasterKey struct {
+	ID  string
+	Key []byte
+}
+
+// NewConfig reads configuration from environment variables:
+//   - OBJECT_STORAGE_DB_PATH, defaults to ./object_storage.db;
+//   - OBJECT_STORAGE_LISTEN, defaults to :11111;
+//   - OBJECT_STORAGE_MAX_TRANSFERS, defaults to 16;
+//   - OBJECT_STORAGE_MAX_TRANSFERS_PER_SERVER, defaults to 4;
+//   - OBJECT_STORAGE_WAIT_TIMEOUT, defaults to 10m;
+//   - OBJECT_STORAGE_READ_AHEAD, defaults to 2;
+//   - OBJECT_STORAGE_READ_AHEAD_BUFFER, defaults to 4194304;
+//   - OBJECT_STORAGE_DOWNLOAD_RETRIES, defaults to 3;
+//   - OBJECT_STORAGE_DOWNLOAD_RETRY_DELAY, defaults to 500ms;
+//   - OBJECT_STORAGE_CHUNK_CACHE_DIR, empty by default;
+//   - OBJECT_STORAGE_CHUNK_CACHE_SIZE, defaults to 1073741824;
+//   - OBJECT_STORAGE_EGRESS_RATE, defaults to 0, which means unlimited;
+//   - OBJECT_STORAGE_IMPORT_ROOT, empty by default;
+//   - OBJECT_STORAGE_IMPORT_TIMEOUT, defaults to 30m;
+//   - OBJECT_STORAGE_IMPORT_ALLOWED_NETS, comma separated list of CIDRs, empty by default;
+//   - OBJECT_STORAGE_MASTER_KEYS, comma separated list of "<key id>:<base64 key>";
+//   - OBJECT_STORAGE_MASTER_KEY_FILE, path to a file with one "<key id>:<base64 key>" per line;
+//   - OBJECT_STORAGE_SIGNING_KEY, base64 key of presigned URLs, empty by default;
+//   - OBJECT_STORAGE_TRASH_RETENTION, defaults to 168h.
+//
+// Keys from environment go before keys from the file.
+func NewConfig() (*Config, error) {
+	res := &Config{
+		DBPath:     getEnv("OBJECT_STORAGE_DB_PATH", "./object_storage.db"),
+		ListenAddr: getEnv("OBJECT_STORAGE_LISTEN", ":11111"),
+		ImportRoot: getEnv("OBJECT_STORAGE_IMPORT_ROOT", ""),
+
+		ChunkCacheDir: getEnv("OBJECT_STORAGE_CHUNK_CACHE_DIR", ""),
+	}
+
+	var err error
+
+	if res.MaxTransfers, err = getEnvInt("OBJECT_STORAGE_MAX_TRANSFERS", 16); err != nil {
+		return nil, err
+	}
+
+	if res.MaxTransfersPerServer, err = getEnvInt("OBJECT_STORAGE_MAX_TRANSFERS_PER_SERVER", 4); err != nil {
+		return nil, err
+	}
+
+	if res.ReadAhead, err = getEnvInt("OBJECT_STORAGE_READ_AHEAD", 2); err != nil {
+		return nil, err
+	}
+
+	if res.ReadAheadBuffer, err = getEnvInt("OBJECT_STORAGE_READ_AHEAD_BUFFER", 4*1024*1024); err != nil {
+		return nil, err
+	}
+
+	if res.DownloadRetries, err = getEnvInt("OBJECT_STORAGE_DOWNLOAD_RETRIES", 3); err != nil {
+		return nil, err
+	}
+
+	if res.DownloadRetryDelay, err = getEnvDuration("OBJECT_STORAGE_DOWNLOAD_RETRY_DELAY", 500*time.Millisecond); err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getEnvInt("OBJECT_STORAGE_CHUNK_CACHE_SIZE", 1024*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	res.ChunkCacheSize = int64(cacheSize)
+
+	egressRate, err := getEnvInt("OBJECT_STORAGE_EGRESS_RATE", 0)
+	if err != nil {
+		return nil, err
+	}
+	res.EgressRate = int64(egressRate)
+
+	if res.WaitTimeout, err = getEnvDuration("OBJECT_STORAGE_WAIT_TIMEOUT", 10*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if res.ImportTimeout, err = getEnvDuration("OBJECT_STORAGE_IMPORT_TIMEOUT", 30*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if env := os.Getenv("OBJECT_STORAGE_IMPORT_ALLOWED_NETS"); env != "" {
+		for _, cidr := range strings.Split(env, ",") {
+			_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return nil, errors.Wrap(err, "OBJECT_STORAGE_IMPORT_ALLOWED_NETS")
+			}
+
+			res.ImportAllowedNets = append(res.ImportAllowedNets, ipNet)
+		}
+	}
+
+	if res.TrashRetention, err = getEnvDuration("OBJECT_STORAGE_TRASH_RETENTION", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	if env := os.Getenv("OBJECT_STORAGE_SIGNING_KEY"); env != "" {
+		if res.SigningKey, err = base64.StdEncoding.DecodeString(env); err != nil {
+			return nil, errors.Wrap(err, "OBJECT_STORAGE_SIGNING_KEY")
+		}
+
+		if len(res.SigningKey) < MinSigningKeySize {
+			return nil, errors.Errorf("OBJECT_STORAGE_SIGNING_KEY must be at least %d bytes long", MinSigningKeySize)
+		}
+	}
+
+	var keys []string
+
+	if env := os.Getenv("OBJECT_STORAGE_MASTER_KEYS"); env != "" {
+		keys = append(keys, strings.Split(env, ",")...)
+	}
+
+	if keyFile := os.Getenv("OBJECT_STORAGE_MASTER_KEY_FILE"); keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, strings.Split(string(content), "\n")...)
+	}
+
+	seen := make(map[string]bool)
+
+	for _, line := range keys {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := parseMasterKey(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[key.ID] {
+			return nil, errors.Errorf("duplicate master key id: %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		res.MasterKeys = append(res.MasterKeys, key)
+	}
+
+	return res, nil
+}
+
+// parseMasterKey parses "<key id>:<base64 key>" string.
+func parseMasterKey(s string) (MasterKey, error) {
+	id, encoded, ok := strings.Cut(s, ":")
+	id = strings.TrimSpace(id)
+	if !ok || id == "" {
+		return MasterKey{}, errors.New("master key must be specified as <key id>:<base64 key>")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return MasterKey{}, errors.Wrapf(err, "master key %q", id)
+	}
+
+	if len(key) != MasterKeySize {
+		return MasterKey{}, errors.Errorf("master key %q must be %d bytes long", id, MasterKeySize)
+	}
+
+	return MasterKey{ID: id, Key: key}, nil
+}
+
+func getEnv(name, def string) string {
+	if res := os.Getenv(name); res != "" {
+		return res
+	}
+
+	return def
+}
+
+func getEnvInt(name string, def int) (int, error) {
+	env := os.Getenv(name)
+	if env == "" {
+		return def, nil
+	}
+
+	res, err := strconv.Atoi(env)
+	if err != nil {
+		return 0, errors.Wrap(err, name)
+	}
+
+	return res, nil
+}
+
+func getEnvDuration(name string, def time.Duration) (time.Duration, error) {
+	env := os.Getenv(name)
+	if env == "" {
+		return def, nil
+	}
+
+	res, err := time.ParseDuration(env)
+	if err != nil {
+		return 0, errors.Wrap(err, name)
+	}
+
+	return res, nil
+}
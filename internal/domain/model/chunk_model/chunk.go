@@ -10,6 +10,7 @@ type Chunk struct {
 	FileServerID string    `json:"file_server_id,omitempty"`
 	FilePath     string    `json:"file_path,omitempty"`
 	Size         int64     `json:"size,omitempty"`
+	StoredSize   int64     `json:"stored_size,omitempty"`
 	Created      time.Time `json:"created,omitempty"`
 	Modified     time.Time `json:"modified,omitempty"`
 }
@@ -0,0 +1,16 @@
+package chunk_model
+
+import "time"
+
+// Deletion represents chunk file queued to be removed from its file server.
+// Chunk model is deleted already, used space of the file server is decreased once the file is removed.
+type Deletion struct {
+	ChunkID      string    `json:"chunk_id,omitempty"`
+	FileServerID string    `json:"file_server_id,omitempty"`
+	FilePath     string    `json:"file_path,omitempty"`
+	StoredSize   int64     `json:"stored_size,omitempty"`
+	Attempts     int       `json:"attempts,omitempty"`
+	NextAttempt  time.Time `json:"next_attempt,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+}
@@ -1,13 +1,26 @@
 package container_model
 
-import "time"
+import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"time"
+)
 
 // Container represents container for items (e.g. folder).
 type Container struct {
-	ID          string    `json:"id,omitempty"`
-	Name        string    `json:"name,omitempty"`
-	Description string    `json:"description,omitempty"`
-	ParentID    string    `json:"parent_id,omitempty"`
-	Created     time.Time `json:"created,omitempty"`
-	Modified    time.Time `json:"modified,omitempty"`
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	ParentID    string                 `json:"parent_id,omitempty"`
+	Compression item_model.Compression `json:"compression,omitempty"`
+	Encryption  item_model.Encryption  `json:"encryption,omitempty"`
+	// CacheControl is sent with downloads of container items, if set.
+	CacheControl string `json:"cache_control,omitempty"`
+	// RateLimit limits rate of every download of container items, bytes per second. Zero means unlimited.
+	RateLimit int64 `json:"rate_limit,omitempty"`
+	// Versioning keeps items stored with the same name as versions of a single item. It can't be turned off.
+	Versioning bool `json:"versioning,omitempty"`
+	// Trashed is a time the container was moved to trash at with its content, nil unless it's in trash.
+	Trashed  *time.Time `json:"trashed,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Modified time.Time  `json:"modified,omitempty"`
 }
@@ -4,14 +4,16 @@ import "time"
 
 // APIFileServer represents file server working via API.
 type APIFileServer struct {
-	ID         string    `json:"id,omitempty"`
-	Name       string    `json:"name,omitempty"`
-	Host       string    `json:"host,omitempty"`
-	Port       string    `json:"port,omitempty"`
-	Endpoint   string    `json:"endpoint,omitempty"`
-	APIVersion string    `json:"api_ersion,omitempty"`
-	User       string    `json:"user,omitempty"`
-	Password   string    `json:"password,omitempty"`
+	ID         string `json:"id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Host       string `json:"host,omitempty"`
+	Port       string `json:"port,omitempty"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	APIVersion string `json:"api_ersion,omitempty"`
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	// RateLimit limits total rate of chunk transfers to the server, bytes per second. Zero means unlimited.
+	RateLimit  int64     `json:"rate_limit,omitempty"`
 	TotalSpace int64     `json:"total_space,omitempty"`
 	UsedSpace  int64     `json:"used_space"`
 	Status     Status    `json:"status,omitempty"`
@@ -32,3 +34,7 @@ func (s *APIFileServer) GetID() string {
 func (s *APIFileServer) GetFreeSpace() int64 {
 	return s.TotalSpace - s.UsedSpace
 }
+
+func (s *APIFileServer) GetRateLimit() int64 {
+	return s.RateLimit
+}
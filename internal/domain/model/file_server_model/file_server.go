@@ -8,6 +8,8 @@ type FileServer interface {
 	GetID() string
 	// GetFreeSpace returns file server space available for storing files.
 	GetFreeSpace() int64
+	// GetRateLimit returns limit of chunk transfers rate, bytes per second. Zero means unlimited.
+	GetRateLimit() int64
 }
 
 type Status string
@@ -4,13 +4,18 @@ import "time"
 
 // SSHFileServer represents file server working via SSH.
 type SSHFileServer struct {
-	ID         string    `json:"id,omitempty"`
-	Name       string    `json:"name,omitempty"`
-	Host       string    `json:"host,omitempty"`
-	Port       string    `json:"port,omitempty"`
-	BasePath   string    `json:"base_path,omitempty"`
-	User       string    `json:"user,omitempty"`
-	Key        string    `json:"key,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Port     string `json:"port,omitempty"`
+	BasePath string `json:"base_path,omitempty"`
+	// ImportPath is a directory files can be imported from, kept apart from chunk files under base path.
+	// Import is disabled if empty.
+	ImportPath string `json:"import_path,omitempty"`
+	User       string `json:"user,omitempty"`
+	Key        string `json:"key,omitempty"`
+	// RateLimit limits total rate of chunk transfers to the server, bytes per second. Zero means unlimited.
+	RateLimit  int64     `json:"rate_limit,omitempty"`
 	TotalSpace int64     `json:"total_space,omitempty"`
 	UsedSpace  int64     `json:"used_space"`
 	Status     Status    `json:"status,omitempty"`
@@ -31,3 +36,7 @@ func (s *SSHFileServer) GetID() string {
 func (s *SSHFileServer) GetFreeSpace() int64 {
 	return s.TotalSpace - s.UsedSpace
 }
+
+func (s *SSHFileServer) GetRateLimit() int64 {
+	return s.RateLimit
+}
@@ -1,6 +1,9 @@
 package item_model
 
-import "time"
+import (
+	"github.com/pkg/errors"
+	"time"
+)
 
 type Status string
 
@@ -14,14 +17,124 @@ const (
 	ItemStatusPending Status = "pending"
 )
 
+// Compression specifies algorithm used to compress item chunks.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Validate checks that compression algorithm is supported.
+func (s Compression) Validate() error {
+	switch s {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return errors.Errorf("unknown compression: %q", s)
+	}
+}
+
+// Encryption specifies cipher used to encrypt item chunks.
+type Encryption string
+
+const (
+	EncryptionNone             Encryption = "none"
+	EncryptionAES256GCM        Encryption = "aes-256-gcm"
+	EncryptionChaCha20Poly1305 Encryption = "chacha20-poly1305"
+)
+
+// Validate checks that cipher is supported.
+func (s Encryption) Validate() error {
+	switch s {
+	case EncryptionNone, EncryptionAES256GCM, EncryptionChaCha20Poly1305:
+		return nil
+	default:
+		return errors.Errorf("unknown encryption: %q", s)
+	}
+}
+
 // Item represents item (currently any byte file) that can be stored and managed by the service.
 type Item struct {
-	ID          string    `json:"id,omitempty"`
-	Name        string    `json:"name,omitempty"`
-	Size        int64     `json:"size,omitempty"`
-	ContainerID string    `json:"container_id,omitempty"`
-	ChunkCount  uint8     `json:"chunk_count,omitempty"`
-	Status      Status    `json:"status,omitempty"`
-	Created     time.Time `json:"created,omitempty"`
-	Modified    time.Time `json:"modified,omitempty"`
+	ID          string      `json:"id,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Size        int64       `json:"size,omitempty"`
+	StoredSize  int64       `json:"stored_size,omitempty"`
+	Hash        string      `json:"hash,omitempty"`
+	ContentType string      `json:"content_type,omitempty"`
+	ContainerID string      `json:"container_id,omitempty"`
+	ChunkCount  uint8       `json:"chunk_count,omitempty"`
+	Status      Status      `json:"status,omitempty"`
+	Compression Compression `json:"compression,omitempty"`
+	Encryption  Encryption  `json:"encryption,omitempty"`
+	KeyID       string      `json:"key_id,omitempty"`
+	DataKey     []byte      `json:"-"`
+	Metadata    Metadata    `json:"metadata,omitempty"`
+	// Version numbers items with the same name in a versioned container, starting from 1. Zero for unversioned items.
+	Version int `json:"version,omitempty"`
+	// DeleteMarker is set for versions marking the item deleted. They have no content.
+	DeleteMarker bool `json:"delete_marker,omitempty"`
+	// Trashed is a time the item was moved to trash at, nil unless it's in trash.
+	Trashed  *time.Time `json:"trashed,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Modified time.Time  `json:"modified,omitempty"`
+}
+
+// Current leaves out items hidden from listings: items in trash, delete markers and versions other than
+// the current one. Current version is the latest stored one, or the latest pending one if nothing is stored yet,
+// so a single version of an item is listed. Items of unversioned containers are listed each.
+func Current(items []Item) []Item {
+	versioned := make(map[string]bool)
+	current := make(map[string]Item)
+
+	for _, itm := range items {
+		if itm.Trashed != nil {
+			continue
+		}
+
+		if itm.Version > 0 {
+			versioned[itm.Name] = true
+		}
+
+		if itm.Status != ItemStatusOK && itm.Status != ItemStatusPending {
+			continue
+		}
+
+		if cur, ok := current[itm.Name]; !ok || supersedes(itm, cur) {
+			current[itm.Name] = itm
+		}
+	}
+
+	res := make([]Item, 0, len(items))
+
+	for _, itm := range items {
+		if itm.Trashed != nil || itm.DeleteMarker {
+			continue
+		}
+
+		if !versioned[itm.Name] || current[itm.Name].ID == itm.ID {
+			res = append(res, itm)
+		}
+	}
+
+	return res
+}
+
+// supersedes reports whether itm takes place of cur as the current version: stored versions win over pending ones,
+// the latest one wins otherwise. Unversioned items of a container versioned later are ordered by creation.
+func supersedes(itm, cur Item) bool {
+	if itm.Status != cur.Status {
+		return itm.Status == ItemStatusOK
+	}
+
+	if itm.Version != cur.Version {
+		return itm.Version > cur.Version
+	}
+
+	if !itm.Created.Equal(cur.Created) {
+		return itm.Created.After(cur.Created)
+	}
+
+	return itm.ID > cur.ID
 }
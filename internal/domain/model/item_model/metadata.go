@@ -0,0 +1,78 @@
+package item_model
+
+import (
+	"github.com/pkg/errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMetadataKeys is a maximum number of metadata entries of an item.
+	MaxMetadataKeys = 64
+	// MaxMetadataSize is a maximum total size of metadata keys and values of an item, bytes.
+	MaxMetadataSize = 8 * 1024
+	// maxMetadataKeySize is a maximum size of a metadata key, bytes.
+	maxMetadataKeySize = 128
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata is user defined key/value pairs of an item. Keys consist of lowercase letters, digits, '-', '_' and '.',
+// so they can be passed as headers. Values are text without control characters.
+type Metadata map[string]string
+
+// Validate checks metadata keys, values and limits.
+func (s Metadata) Validate() error {
+	if len(s) > MaxMetadataKeys {
+		return errors.Wrapf(ErrInvalidMetadata, "more than %d keys", MaxMetadataKeys)
+	}
+
+	var size int
+
+	for k, v := range s {
+		if err := ValidateMetadataKey(k); err != nil {
+			return err
+		}
+
+		if !utf8.ValidString(v) {
+			return errors.Wrapf(ErrInvalidMetadata, "value of %q isn't valid UTF-8", k)
+		}
+
+		for _, r := range v {
+			if unicode.IsControl(r) {
+				return errors.Wrapf(ErrInvalidMetadata, "value of %q contains control characters", k)
+			}
+		}
+
+		size += len(k) + len(v)
+	}
+
+	if size > MaxMetadataSize {
+		return errors.Wrapf(ErrInvalidMetadata, "larger than %d bytes", MaxMetadataSize)
+	}
+
+	return nil
+}
+
+// ValidateMetadataKey checks that metadata key is allowed.
+func ValidateMetadataKey(key string) error {
+	if key == "" || len(key) > maxMetadataKeySize {
+		return errors.Wrapf(ErrInvalidMetadata, "key %q must be from 1 to %d bytes long", key, maxMetadataKeySize)
+	}
+
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' && r != '.' {
+			return errors.Wrapf(ErrInvalidMetadata, "key %q contains characters other than a-z, 0-9, '-', '_' and '.'", key)
+		}
+	}
+
+	return nil
+}
+
+// ListFilter limits listed items to ones with the status, if it's not empty, and containing all metadata entries.
+// Trashed lists items in trash instead of other ones.
+type ListFilter struct {
+	Status   Status
+	Metadata Metadata
+	Trashed  bool
+}
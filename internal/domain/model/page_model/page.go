@@ -0,0 +1,153 @@
+package page_model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultLimit is a page size used if it's not specified.
+	DefaultLimit = 100
+	// MaxLimit is the largest page size allowed.
+	MaxLimit = 1000
+)
+
+var ErrInvalidParams = errors.New("invalid list parameters")
+
+// Sort specifies field entities are sorted by.
+type Sort string
+
+const (
+	SortName    Sort = "name"
+	SortSize    Sort = "size"
+	SortCreated Sort = "created"
+)
+
+// Order specifies sorting direction.
+type Order string
+
+const (
+	OrderAsc  Order = "asc"
+	OrderDesc Order = "desc"
+)
+
+// Cursor is a position in a sorted list, right after the last entity of a page.
+// Key is the sort field value of the entity, ID breaks ties between equal keys.
+type Cursor struct {
+	Sort  Sort   `json:"s"`
+	Order Order  `json:"o"`
+	Key   string `json:"k"`
+	ID    string `json:"i"`
+}
+
+// Encode returns opaque token of the cursor.
+func (s Cursor) Encode() string {
+	bytes, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+// Params are list parameters requested by client. Empty fields get defaults: sorting by name ascending, first page
+// of DefaultLimit entities.
+type Params struct {
+	Sort   Sort   `json:"sort,omitempty"`
+	Order  Order  `json:"order,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// Query specifies a page of sorted list.
+type Query struct {
+	Sort  Sort
+	Order Order
+	// Prefix limits list to entities which names start with it, case-sensitive.
+	Prefix string
+	// After is a position the page starts after, the first page is requested if nil.
+	After *Cursor
+	Limit int
+}
+
+// Query validates parameters and turns them into a query. Sorting is allowed by the specified fields only.
+func (s Params) Query(sorts ...Sort) (Query, error) {
+	res := Query{
+		Sort:   s.Sort,
+		Order:  s.Order,
+		Prefix: s.Prefix,
+		Limit:  s.Limit,
+	}
+
+	if res.Sort == "" {
+		res.Sort = SortName
+	}
+
+	if !sortAllowed(res.Sort, sorts) {
+		return Query{}, errors.Wrapf(ErrInvalidParams, "can't sort by %q", res.Sort)
+	}
+
+	if res.Order == "" {
+		res.Order = OrderAsc
+	}
+
+	if res.Order != OrderAsc && res.Order != OrderDesc {
+		return Query{}, errors.Wrapf(ErrInvalidParams, "unknown order %q", res.Order)
+	}
+
+	if res.Limit == 0 {
+		res.Limit = DefaultLimit
+	}
+
+	if res.Limit < 0 || res.Limit > MaxLimit {
+		return Query{}, errors.Wrapf(ErrInvalidParams, "limit must be between 1 and %d", MaxLimit)
+	}
+
+	if s.Cursor != "" {
+		cursor, err := decodeCursor(s.Cursor)
+		if err != nil {
+			return Query{}, err
+		}
+
+		if cursor.Sort != res.Sort || cursor.Order != res.Order {
+			return Query{}, errors.Wrap(ErrInvalidParams, "cursor doesn't match sorting")
+		}
+
+		res.After = &cursor
+	}
+
+	return res, nil
+}
+
+// Next returns cursor after the entity with the key and ID.
+func (s Query) Next(key, id string) Cursor {
+	return Cursor{
+		Sort:  s.Sort,
+		Order: s.Order,
+		Key:   key,
+		ID:    id,
+	}
+}
+
+func decodeCursor(token string) (Cursor, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, errors.Wrap(ErrInvalidParams, "malformed cursor")
+	}
+
+	var res Cursor
+
+	if err = json.Unmarshal(bytes, &res); err != nil || res.ID == "" {
+		return Cursor{}, errors.Wrap(ErrInvalidParams, "malformed cursor")
+	}
+
+	return res, nil
+}
+
+func sortAllowed(sort Sort, sorts []Sort) bool {
+	for _, s := range sorts {
+		if s == sort {
+			return true
+		}
+	}
+
+	return false
+}
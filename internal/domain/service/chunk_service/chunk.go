@@ -8,6 +8,13 @@ import (
 	"time"
 )
 
+const (
+	// deletionRetryDelay is a delay before the first retry of failed chunk deletion, every next one waits twice longer.
+	deletionRetryDelay = 10 * time.Second
+	// maxDeletionRetryDelay limits delay between retries of chunk deletion.
+	maxDeletionRetryDelay = time.Hour
+)
+
 // Service provides methods to manage chunks.
 type Service struct {
 	storage chunkStorage
@@ -48,6 +55,7 @@ func (s *Service) Create(ctx context.Context, dto CreateChunkDTO) (chunk_model.C
 		FileServerID: dto.FileServerID,
 		FilePath:     dto.FilePath,
 		Size:         dto.Size,
+		StoredSize:   dto.StoredSize,
 		Created:      now,
 		Modified:     now,
 	}
@@ -60,9 +68,48 @@ func (s *Service) Create(ctx context.Context, dto CreateChunkDTO) (chunk_model.C
 	return newChunk, nil
 }
 
+// Delete deletes chunk model and queues chunk file to be removed from its file server.
 func (s *Service) Delete(ctx context.Context, chunk chunk_model.Chunk) error {
-	// TODO implement
-	return nil
+	return s.storage.Delete(ctx, chunk)
+}
+
+// DeleteFile queues file stored on file server without chunk model to be removed. Used space of the file server
+// isn't accounted for such files, so it isn't released once the file is removed.
+func (s *Service) DeleteFile(ctx context.Context, fileServerID, filePath string) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	return s.storage.QueueDeletion(ctx, chunk_model.Deletion{
+		ChunkID:      id.String(),
+		FileServerID: fileServerID,
+		FilePath:     filePath,
+		NextAttempt:  now,
+		Created:      now,
+	})
+}
+
+// DueDeletions returns queued chunk deletions, which are due to be attempted.
+func (s *Service) DueDeletions(ctx context.Context, limit int) ([]chunk_model.Deletion, error) {
+	return s.storage.ListDeletions(ctx, time.Now(), limit)
+}
+
+// CompleteDeletion removes deletion of removed chunk file from the queue and releases used space of file server.
+func (s *Service) CompleteDeletion(ctx context.Context, deletion chunk_model.Deletion) error {
+	return s.storage.CompleteDeletion(ctx, deletion)
+}
+
+// RetryDeletion schedules failed deletion to be retried with exponential backoff.
+func (s *Service) RetryDeletion(ctx context.Context, deletion chunk_model.Deletion, cause error) error {
+	delay := maxDeletionRetryDelay
+	if deletion.Attempts < 16 && deletionRetryDelay<<deletion.Attempts < maxDeletionRetryDelay {
+		delay = deletionRetryDelay << deletion.Attempts
+	}
+
+	return s.storage.RetryDeletion(ctx, deletion.ChunkID, time.Now().Add(delay), cause.Error())
 }
 
 // GetItemChunks returns chunks of specified Item
@@ -3,6 +3,7 @@ package chunk_service
 import (
 	"context"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
+	"time"
 )
 
 type chunkStorage interface {
@@ -10,4 +11,8 @@ type chunkStorage interface {
 	Create(ctx context.Context, chunk chunk_model.Chunk) error
 	Delete(ctx context.Context, chunk chunk_model.Chunk) error
 	GetItemChunks(ctx context.Context, id string) ([]chunk_model.Chunk, error)
+	QueueDeletion(ctx context.Context, deletion chunk_model.Deletion) error
+	ListDeletions(ctx context.Context, before time.Time, limit int) ([]chunk_model.Deletion, error)
+	CompleteDeletion(ctx context.Context, deletion chunk_model.Deletion) error
+	RetryDeletion(ctx context.Context, id string, nextAttempt time.Time, lastError string) error
 }
@@ -6,4 +6,5 @@ type CreateChunkDTO struct {
 	FileServerID string
 	FilePath     string
 	Size         int64
+	StoredSize   int64
 }
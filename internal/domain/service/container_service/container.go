@@ -3,6 +3,7 @@ package container_service
 import (
 	"context"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	"github.com/gofrs/uuid/v5"
 	log "github.com/sirupsen/logrus"
 	"time"
@@ -32,6 +33,16 @@ func (s Service) Get(ctx context.Context, id string) (container_model.Container,
 	return res, nil
 }
 
+// GetByName returns child container of the parent by name. Root containers have empty parent ID.
+func (s Service) GetByName(ctx context.Context, parentID, name string) (container_model.Container, error) {
+	res, err := s.storage.GetByName(ctx, parentID, name)
+	if err != nil {
+		return container_model.Container{}, err
+	}
+
+	return res, nil
+}
+
 // Create creates and returns new container model.
 func (s Service) Create(ctx context.Context, dto CreateContainerDTO) (container_model.Container, error) {
 	newID, err := uuid.NewV7()
@@ -42,12 +53,17 @@ func (s Service) Create(ctx context.Context, dto CreateContainerDTO) (container_
 	now := time.Now()
 
 	newContainer := container_model.Container{
-		ID:          newID.String(),
-		Name:        dto.Name,
-		Description: dto.Description,
-		ParentID:    dto.ParentID,
-		Created:     now,
-		Modified:    now,
+		ID:           newID.String(),
+		Name:         dto.Name,
+		Description:  dto.Description,
+		ParentID:     dto.ParentID,
+		Compression:  dto.Compression,
+		Encryption:   dto.Encryption,
+		CacheControl: dto.CacheControl,
+		RateLimit:    dto.RateLimit,
+		Versioning:   dto.Versioning,
+		Created:      now,
+		Modified:     now,
 	}
 
 	err = s.storage.Create(ctx, newContainer)
@@ -68,7 +84,58 @@ func (s Service) List(ctx context.Context) ([]container_model.Container, error)
 	return containers, nil
 }
 
+// ListPage returns a page of containers and total number of containers matching the query.
+// Either containers in trash or other ones are listed. One container more than the limit is returned if there is the next page.
+func (s Service) ListPage(ctx context.Context, trashed bool, q page_model.Query) ([]container_model.Container, int, error) {
+	return s.storage.ListPage(ctx, trashed, q)
+}
+
+// Move sets parent and name of the container and returns updated model.
+func (s Service) Move(ctx context.Context, cont container_model.Container, parentID, name string) (container_model.Container, error) {
+	if err := s.storage.Move(ctx, cont.ID, parentID, name); err != nil {
+		return container_model.Container{}, err
+	}
+
+	cont.ParentID = parentID
+	cont.Name = name
+	cont.Modified = time.Now()
+
+	return cont, nil
+}
+
+// EnableVersioning turns on versioning of container items and returns updated model.
+func (s Service) EnableVersioning(ctx context.Context, cont container_model.Container) (container_model.Container, error) {
+	if err := s.storage.EnableVersioning(ctx, cont.ID); err != nil {
+		return container_model.Container{}, err
+	}
+
+	cont.Versioning = true
+	cont.Modified = time.Now()
+
+	return cont, nil
+}
+
 // Delete removes container by ID.
 func (s Service) Delete(ctx context.Context, id string) error {
 	return s.storage.Delete(ctx, id)
 }
+
+// Trash moves container to trash, together with its child containers and items if recursive is set.
+func (s Service) Trash(ctx context.Context, id string, recursive bool) error {
+	return s.storage.Trash(ctx, id, time.Now(), recursive)
+}
+
+// Restore takes container out of trash together with content moved to trash with it.
+func (s Service) Restore(ctx context.Context, id string) error {
+	return s.storage.Restore(ctx, id)
+}
+
+// ListTrashed returns containers moved to trash before the time.
+func (s Service) ListTrashed(ctx context.Context, before time.Time) ([]container_model.Container, error) {
+	return s.storage.ListTrashed(ctx, before)
+}
+
+// DeleteTrashed removes empty container moved to trash before the time.
+func (s Service) DeleteTrashed(ctx context.Context, id string, before time.Time) error {
+	return s.storage.DeleteTrashed(ctx, id, before)
+}
@@ -3,11 +3,21 @@ package container_service
 import (
 	"context"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
+	"time"
 )
 
 type containerStorage interface {
 	Get(ctx context.Context, id string) (container_model.Container, error)
+	GetByName(ctx context.Context, parentID, name string) (container_model.Container, error)
 	List(ctx context.Context) ([]container_model.Container, error)
+	ListPage(ctx context.Context, trashed bool, q page_model.Query) ([]container_model.Container, int, error)
 	Create(ctx context.Context, container container_model.Container) error
+	Move(ctx context.Context, id, parentID, name string) error
+	EnableVersioning(ctx context.Context, id string) error
 	Delete(ctx context.Context, id string) error
+	Trash(ctx context.Context, id string, at time.Time, recursive bool) error
+	Restore(ctx context.Context, id string) error
+	ListTrashed(ctx context.Context, before time.Time) ([]container_model.Container, error)
+	DeleteTrashed(ctx context.Context, id string, before time.Time) error
 }
@@ -1,7 +1,14 @@
 package container_service
 
+import "github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+
 type CreateContainerDTO struct {
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	ParentID    string `json:"parent_id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	ParentID     string                 `json:"parent_id,omitempty"`
+	Compression  item_model.Compression `json:"compression,omitempty"`
+	Encryption   item_model.Encryption  `json:"encryption,omitempty"`
+	CacheControl string                 `json:"cache_control,omitempty"`
+	RateLimit    int64                  `json:"rate_limit,omitempty"`
+	Versioning   bool                   `json:"versioning,omitempty"`
 }
@@ -3,7 +3,9 @@ package file_server_service
 import (
 	"encoding/json"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/file_server_model"
+	"github.com/pkg/errors"
 	"os"
+	"path"
 )
 
 type AddFileServerDTO interface {
@@ -22,11 +24,16 @@ type AddAPIFileServerDTO struct {
 	APIVersion string `json:"api_version,omitempty"`
 	User       string `json:"user,omitempty"`
 	Password   string `json:"password,omitempty"`
+	RateLimit  int64  `json:"rate_limit,omitempty"`
 	TotalSpace int64  `json:"total_space,omitempty"`
 }
 
 func (s AddAPIFileServerDTO) Validate() error {
 	// TODO validation
+	if s.RateLimit < 0 {
+		return errors.New("rate limit can't be negative")
+	}
+
 	return nil
 }
 
@@ -58,13 +65,32 @@ type AddSSHFileServerDTO struct {
 	Address    string `json:"address,omitempty"`
 	Port       string `json:"port,omitempty"`
 	BasePath   string `json:"base_path,omitempty"`
+	ImportPath string `json:"import_path,omitempty"`
 	User       string `json:"user,omitempty"`
 	KeyFile    string `json:"key_file,omitempty"`
+	RateLimit  int64  `json:"rate_limit,omitempty"`
 	TotalSpace int64  `json:"total_space,omitempty"`
 }
 
 func (s AddSSHFileServerDTO) Validate() error {
 	// TODO validation
+	if s.RateLimit < 0 {
+		return errors.New("rate limit can't be negative")
+	}
+
+	// Chunk files must not be importable.
+	if s.ImportPath != "" {
+		basePath, importPath := path.Clean(s.BasePath), path.Clean(s.ImportPath)
+
+		if !path.IsAbs(importPath) {
+			return errors.New("import path must be absolute")
+		}
+
+		if withinPath(basePath, importPath) || withinPath(importPath, basePath) {
+			return errors.New("import path can't overlap base path")
+		}
+	}
+
 	return nil
 }
 
@@ -87,17 +113,21 @@ func (s AddSSHFileServerDTO) MarshalParams() (string, error) {
 	}
 
 	temp := struct {
-		Address  string `json:"address,omitempty"`
-		Port     string `json:"port,omitempty"`
-		BasePath string `json:"base_path,omitempty"`
-		User     string `json:"user,omitempty"`
-		Key      string `json:"key,omitempty"`
+		Address    string `json:"address,omitempty"`
+		Port       string `json:"port,omitempty"`
+		BasePath   string `json:"base_path,omitempty"`
+		ImportPath string `json:"import_path,omitempty"`
+		User       string `json:"user,omitempty"`
+		Key        string `json:"key,omitempty"`
+		RateLimit  int64  `json:"rate_limit,omitempty"`
 	}{
 		s.Address,
 		s.Port,
 		s.BasePath,
+		s.ImportPath,
 		s.User,
 		string(key),
+		s.RateLimit,
 	}
 
 	res, err := json.Marshal(temp)
@@ -7,28 +7,48 @@ import (
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/file_server_model"
 	"github.com/PavelKhripkov/object_storage/pkg/client/ssh"
+	"github.com/PavelKhripkov/object_storage/pkg/disk_cache"
+	"github.com/PavelKhripkov/object_storage/pkg/throttle"
 	"github.com/gofrs/uuid/v5"
 	"github.com/pkg/errors"
 	"github.com/pkg/sftp"
 	log "github.com/sirupsen/logrus"
 	"io"
-	"mime/multipart"
+	"os"
 	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// maxSymlinks limits number of symlinks followed while resolving a path.
+const maxSymlinks = 40
+
+var (
+	ErrImportDisabled  = errors.New("import from the file server is disabled")
+	ErrOutOfImportPath = errors.New("path is out of import path")
+	ErrFileNotFound    = errors.New("file not found")
+)
+
 // Service provides methods to engage file servers.
 type Service struct {
-	storage fileServerStorage
-	l       *log.Entry
+	storage    fileServerStorage
+	chunkCache *disk_cache.Cache
+	egress     *throttle.Bucket
+	buckets    *serverBuckets
+	l          *log.Entry
 }
 
-// NewFileServerService creates new file server service.
-func NewFileServerService(fileServerStorage fileServerStorage, l *log.Logger) *Service {
+// NewFileServerService creates new file server service. Chunk files are read through chunkCache, if it's not nil.
+// Chunk transfers are limited by rate limits of file servers and by egress bucket shared with other outgoing streams.
+func NewFileServerService(fileServerStorage fileServerStorage, chunkCache *disk_cache.Cache, egress *throttle.Bucket, l *log.Logger) *Service {
 	return &Service{
-		storage: fileServerStorage,
-		l:       l.WithField("component", "FileServerService"),
+		storage:    fileServerStorage,
+		chunkCache: chunkCache,
+		egress:     egress,
+		buckets:    &serverBuckets{buckets: make(map[string]*throttle.Bucket)},
+		l:          l.WithField("component", "FileServerService"),
 	}
 }
 
@@ -214,34 +234,39 @@ func (s Service) UpdateUsedSpace(ctx context.Context, id string, change int64) e
 	return s.storage.UpdateUsedSpace(ctx, id, change)
 }
 
-// StoreChunk stores item chunk to specified file server.
-func (s Service) StoreChunk(ctx context.Context, fileServer file_server_model.FileServer, file *multipart.FileHeader, start, size int64) (string, error) {
+// StoreChunk stores item chunk read from src to specified file server.
+// Returns path of the stored file and number of bytes written.
+// Transfer rate is limited by the file server limit, shared by all transfers to the server, and by egress limit.
+func (s Service) StoreChunk(ctx context.Context, fileServer file_server_model.FileServer, src io.Reader) (string, int64, error) {
+	src = throttle.NewReader(ctx, src, s.buckets.get(fileServer.GetID(), fileServer.GetRateLimit()), s.egress)
+
 	var (
-		res string
-		err error
+		res     string
+		written int64
+		err     error
 	)
 
 	switch fs := fileServer.(type) {
 	case *file_server_model.SSHFileServer:
-		res, err = s.storeOnSSH(ctx, fs, file, start, size)
+		res, written, err = s.storeOnSSH(ctx, fs, src)
 	case *file_server_model.APIFileServer:
-		res, err = s.storeOnAPI(ctx, fs, file, start, size)
+		res, written, err = s.storeOnAPI(ctx, fs, src)
 	default:
-		return "", errors.New("unknown file server type")
+		return "", 0, errors.New("unknown file server type")
 	}
 
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	return res, nil
+	return res, written, nil
 }
 
 // storeOnSSH implements storing item chunk on a file server via SSH.
-func (s Service) storeOnSSH(ctx context.Context, fs *file_server_model.SSHFileServer, file *multipart.FileHeader, start, size int64) (string, error) {
+func (s Service) storeOnSSH(ctx context.Context, fs *file_server_model.SSHFileServer, src io.Reader) (string, int64, error) {
 	client, closeFunc, err := ssh.NewClient(ctx, fs.Host, fs.Port, fs.User, fs.Key)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer func() {
 		if err := closeFunc(); err != nil {
@@ -253,7 +278,7 @@ func (s Service) storeOnSSH(ctx context.Context, fs *file_server_model.SSHFileSe
 
 	fileName, err := uuid.NewV7()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	relativePath := path.Join(dir, fileName.String())
@@ -262,12 +287,12 @@ func (s Service) storeOnSSH(ctx context.Context, fs *file_server_model.SSHFileSe
 
 	err = client.MkdirAll(path.Join(fs.BasePath, dir))
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	dstFile, err := client.Create(dst)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer func() {
 		if err := dstFile.Close(); err != nil {
@@ -275,34 +300,39 @@ func (s Service) storeOnSSH(ctx context.Context, fs *file_server_model.SSHFileSe
 		}
 	}()
 
-	f, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-
-	defer func() {
-		if err := f.Close(); err != nil {
-			s.l.Error(err)
-		}
-	}()
-
-	_, err = f.Seek(start, io.SeekStart)
+	written, err := io.Copy(dstFile, src)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	_, err = io.CopyN(dstFile, f, size)
-	if err != nil {
-		return "", err
-	}
-
-	return relativePath, nil
+	return relativePath, written, nil
 }
 
 // storeOnAPI implements storing item chunk on a file server via API.
-func (s Service) storeOnAPI(ctx context.Context, fs *file_server_model.APIFileServer, file *multipart.FileHeader, start, size int64) (string, error) {
+func (s Service) storeOnAPI(ctx context.Context, fs *file_server_model.APIFileServer, src io.Reader) (string, int64, error) {
 	// TODO implement.
-	return "", nil
+	return "", 0, nil
+}
+
+// serverBuckets keeps rate limiting buckets of file servers.
+type serverBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*throttle.Bucket
+}
+
+// get returns bucket of the file server, bucket is recreated if the server rate has changed.
+func (s *serverBuckets) get(id string, rate int64) *throttle.Bucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := s.buckets[id]
+
+	if res.Rate() != rate {
+		res = throttle.NewBucket(rate)
+		s.buckets[id] = res
+	}
+
+	return res
 }
 
 // buildFilePath creates a path to store file on.
@@ -320,6 +350,7 @@ func buildFilePath() string {
 }
 
 // OpenChunkFile opens remote file representing chunk to be read in stream mode. Returned object must be closed after usage.
+// If chunk cache is enabled, cached chunk file is opened instead, and remote file is cached once it's read.
 func (s Service) OpenChunkFile(ctx context.Context, chnk chunk_model.Chunk) (func() (io.ReadSeekCloser, error), error) {
 	commonFileServer, err := s.storage.Get(ctx, chnk.FileServerID)
 	if err != nil {
@@ -346,9 +377,208 @@ func (s Service) OpenChunkFile(ctx context.Context, chnk chunk_model.Chunk) (fun
 		return nil, err
 	}
 
+	if s.chunkCache == nil {
+		return res, nil
+	}
+
+	open := res
+
+	return func() (io.ReadSeekCloser, error) {
+		if f, ok := s.chunkCache.Open(chnk.ID); ok {
+			return f, nil
+		}
+
+		f, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		return s.chunkCache.Populate(chnk.ID, f), nil
+	}, nil
+}
+
+// CacheStats returns usage of chunk cache. Reports false if cache is disabled.
+func (s Service) CacheStats() (disk_cache.Stats, bool) {
+	if s.chunkCache == nil {
+		return disk_cache.Stats{}, false
+	}
+
+	return s.chunkCache.Stats(), true
+}
+
+// OpenFile opens file within import path of file server to import it. Relative paths are resolved against import path.
+// Chunk files under base path can't be opened. Returned object must be closed after usage.
+func (s Service) OpenFile(ctx context.Context, fileServerID, filePath string) (io.ReadSeekCloser, error) {
+	fileServer, err := s.Get(ctx, fileServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, ok := fileServer.(*file_server_model.SSHFileServer)
+	if !ok {
+		return nil, errors.Wrap(ErrImportDisabled, "opening files is supported on SSH file servers only")
+	}
+
+	if fs.ImportPath == "" {
+		return nil, ErrImportDisabled
+	}
+
+	if !path.IsAbs(filePath) {
+		filePath = path.Join(fs.ImportPath, filePath)
+	}
+
+	client, closeFunc, err := ssh.NewClient(ctx, fs.Host, fs.Port, fs.User, fs.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteFile, err := openWithin(client, fs.ImportPath, fs.BasePath, filePath)
+	if err != nil {
+		if err := closeFunc(); err != nil {
+			s.l.Error(err)
+		}
+		return nil, err
+	}
+
+	return &sftpWrapper{
+		closeClient: closeFunc,
+		file:        remoteFile,
+	}, nil
+}
+
+// openWithin opens file, unless it's out of import path or within base path holding chunk files.
+// Paths are resolved, so neither ".." elements, nor symlinks lead out of import path or into base path.
+func openWithin(client *sftp.Client, importPath, basePath, filePath string) (*sftp.File, error) {
+	// Checked before resolving too, so paths out of import path aren't even looked up.
+	if !withinPath(path.Clean(importPath), path.Clean(filePath)) {
+		return nil, errors.Wrap(ErrOutOfImportPath, filePath)
+	}
+
+	root, err := resolvePath(client, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkRoot, err := resolvePath(client, basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolvePath(client, filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrap(ErrFileNotFound, filePath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !withinPath(root, resolved) || withinPath(chunkRoot, resolved) {
+		return nil, errors.Wrap(ErrOutOfImportPath, filePath)
+	}
+
+	return client.Open(resolved)
+}
+
+// withinPath reports whether path is the root or within it.
+func withinPath(root, filePath string) bool {
+	return filePath == root || strings.HasPrefix(filePath, strings.TrimSuffix(root, "/")+"/")
+}
+
+// resolvePath returns absolute path with symlinks resolved. Servers don't always resolve symlinks themselves,
+// so path elements are checked one by one.
+func resolvePath(client *sftp.Client, filePath string) (string, error) {
+	filePath, err := client.RealPath(path.Clean(filePath))
+	if err != nil {
+		return "", err
+	}
+
+	res := "/"
+	rest := strings.Split(filePath, "/")
+
+	for links := 0; len(rest) > 0; {
+		elem := rest[0]
+		rest = rest[1:]
+
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			res = path.Dir(res)
+			continue
+		}
+
+		next := path.Join(res, elem)
+
+		info, err := client.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			res = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", errors.Errorf("too many symlinks in path %s", filePath)
+		}
+
+		target, err := client.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+
+		// Relative target is resolved against directory of the link.
+		if !path.IsAbs(target) {
+			target = path.Join(res, target)
+		}
+
+		rest = append(strings.Split(target, "/"), rest...)
+		res = "/"
+	}
+
 	return res, nil
 }
 
+// RemoveChunkFile removes chunk file from file server. Missing file is considered removed already.
+func (s Service) RemoveChunkFile(ctx context.Context, fileServerID, filePath string) error {
+	fileServer, err := s.Get(ctx, fileServerID)
+	if err != nil {
+		return err
+	}
+
+	switch fs := fileServer.(type) {
+	case *file_server_model.SSHFileServer:
+		return s.removeOnSSH(ctx, fs, filePath)
+	case *file_server_model.APIFileServer:
+		// TODO implement.
+		return errors.New("removing files isn't implemented for API file servers")
+	default:
+		return errors.New("unknown file server type")
+	}
+}
+
+// removeOnSSH implements removing chunk file via SSH.
+func (s Service) removeOnSSH(ctx context.Context, fs *file_server_model.SSHFileServer, filePath string) error {
+	client, closeFunc, err := ssh.NewClient(ctx, fs.Host, fs.Port, fs.User, fs.Key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := closeFunc(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	err = client.Remove(path.Join(fs.BasePath, filePath))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
 // openOnSSH implements stream access to chunk file via SSH.
 func (s Service) openOnSSH(ctx context.Context, fileServer *file_server_model.SSHFileServer, chnk chunk_model.Chunk) (func() (io.ReadSeekCloser, error), error) {
 
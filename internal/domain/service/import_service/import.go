@@ -0,0 +1,273 @@
+package import_service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout  = 30 * time.Second
+	maxRedirects = 10
+)
+
+var (
+	ErrLocalImportDisabled = errors.New("import from local path is disabled")
+	ErrAddressForbidden    = errors.New("import from the address is forbidden")
+	ErrInvalidSource       = errors.New("invalid import source")
+	ErrSourceNotFound      = errors.New("import source not found")
+)
+
+// Service pulls items from external sources to the local server.
+type Service struct {
+	localRoot string
+	maxSize   int64
+	client    *http.Client
+	l         *log.Entry
+}
+
+// NewImportService creates new import service. Local files can be imported from localRoot only,
+// empty localRoot disables local import. Sources larger than maxSize are rejected. Downloads of URLs are limited
+// by timeout and can't reach loopback, private and link-local addresses, unless they are within allowedNets.
+func NewImportService(localRoot string, maxSize int64, timeout time.Duration, allowedNets []*net.IPNet, l *log.Logger) *Service {
+	return &Service{
+		localRoot: localRoot,
+		maxSize:   maxSize,
+		client:    newClient(timeout, allowedNets),
+		l:         l.WithField("component", "ImportService"),
+	}
+}
+
+// newClient creates HTTP client checking addresses on every connection, so neither redirects,
+// nor host names resolved to other addresses bypass the check. Proxies aren't used for the same reason.
+func newClient(timeout time.Duration, allowedNets []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allowedNets)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.Errorf("unsupported URL scheme %q", req.URL.Scheme)
+			}
+
+			return nil
+		},
+	}
+}
+
+// checkAddress returns ErrAddressForbidden if the address is loopback, private, link-local or otherwise
+// not public one, unless it's within allowed networks.
+func checkAddress(address string, allowedNets []*net.IPNet) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("invalid IP address %q", host)
+	}
+
+	for _, allowed := range allowedNets {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return errors.Wrapf(ErrAddressForbidden, "%s", ip)
+	}
+
+	return nil
+}
+
+// Source represents an item source available on the local server.
+type Source struct {
+	Name string
+	Size int64
+	// ContentType is a media type reported by the source, if any.
+	ContentType string
+
+	path      string
+	temporary bool
+}
+
+// Open opens the source for reading.
+func (s Source) Open() (multipart.File, error) {
+	return os.Open(s.path)
+}
+
+// Remove removes the source if it's a temporary copy. Local files are kept.
+func (s Source) Remove() error {
+	if !s.temporary {
+		return nil
+	}
+
+	return os.Remove(s.path)
+}
+
+// FromURL downloads HTTP(S) resource to a temporary file.
+func (s *Service) FromURL(ctx context.Context, rawURL string) (Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Source{}, errors.Wrap(ErrInvalidSource, err.Error())
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Source{}, errors.Wrapf(ErrInvalidSource, "unsupported URL scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Source{}, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Source{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return Source{}, errors.Wrapf(ErrSourceNotFound, "response status: %s", resp.Status)
+	default:
+		return Source{}, errors.Wrapf(ErrInvalidSource, "unexpected response status: %s", resp.Status)
+	}
+
+	if resp.ContentLength > s.maxSize {
+		return Source{}, errors.Wrapf(ErrInvalidSource, "source is too large: %d bytes", resp.ContentLength)
+	}
+
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = u.Host
+	}
+
+	res, err := s.FromReader(name, resp.Body)
+	if err != nil {
+		return Source{}, err
+	}
+
+	res.ContentType = resp.Header.Get("Content-Type")
+
+	return res, nil
+}
+
+// FromLocal returns source of a regular file within local root.
+// Relative paths are resolved against local root.
+func (s *Service) FromLocal(filePath string) (Source, error) {
+	if s.localRoot == "" {
+		return Source{}, ErrLocalImportDisabled
+	}
+
+	root, err := filepath.EvalSymlinks(s.localRoot)
+	if err != nil {
+		return Source{}, err
+	}
+
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(root, filePath)
+	}
+
+	// Symlinks are resolved, so they can't point out of local root.
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Source{}, errors.Wrap(ErrSourceNotFound, err.Error())
+	}
+
+	if err != nil {
+		return Source{}, err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return Source{}, errors.Wrapf(ErrInvalidSource, "path %s is out of import root", filePath)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return Source{}, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return Source{}, errors.Wrapf(ErrInvalidSource, "%s is not a regular file", filePath)
+	}
+
+	if info.Size() > s.maxSize {
+		return Source{}, errors.Errorf("source is too large: %d bytes", info.Size())
+	}
+
+	return Source{
+		Name: filepath.Base(resolved),
+		Size: info.Size(),
+		path: resolved,
+	}, nil
+}
+
+// FromReader copies source to a temporary file.
+func (s *Service) FromReader(name string, src io.Reader) (res Source, err error) {
+	f, err := os.CreateTemp("", "object-storage-import-")
+	if err != nil {
+		return Source{}, err
+	}
+
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			if err := os.Remove(f.Name()); err != nil {
+				s.l.Error(err)
+			}
+		}
+	}()
+
+	written, err := io.Copy(f, io.LimitReader(src, s.maxSize+1))
+	if err != nil {
+		return Source{}, err
+	}
+
+	if written > s.maxSize {
+		return Source{}, errors.Wrap(ErrInvalidSource, "source is too large")
+	}
+
+	return Source{
+		Name:      name,
+		Size:      written,
+		path:      f.Name(),
+		temporary: true,
+	}, nil
+}
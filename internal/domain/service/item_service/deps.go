@@ -2,13 +2,25 @@ package item_service
 
 import (
 	"context"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
+	"time"
 )
 
 type itemStorage interface {
 	Get(ctx context.Context, id string) (item_model.Item, error)
 	List(ctx context.Context, containerID string) ([]item_model.Item, error)
-	Create(ctx context.Context, item item_model.Item) error
+	ListByName(ctx context.Context, containerID, name string) ([]item_model.Item, error)
+	ListPage(ctx context.Context, containerID string, filter item_model.ListFilter, q page_model.Query) ([]item_model.Item, int, error)
+	Create(ctx context.Context, item item_model.Item, versioned bool) (int, error)
 	Update(ctx context.Context, item item_model.Item) error
-	Delete(ctx context.Context, item *item_model.Item) error
+	CreateCopy(ctx context.Context, item item_model.Item, chunks []chunk_model.Chunk, versioned bool) (int, error)
+	Delete(ctx context.Context, id string) error
+	Trash(ctx context.Context, id string, at time.Time) error
+	Restore(ctx context.Context, id string) error
+	ListTrashed(ctx context.Context, before time.Time) ([]item_model.Item, error)
+	DeleteTrashed(ctx context.Context, id string, before time.Time) error
+	UpdateDataKey(ctx context.Context, id, keyID string, dataKey []byte) error
+	ListWrappedWithOtherKey(ctx context.Context, keyID string) ([]item_model.Item, error)
 }
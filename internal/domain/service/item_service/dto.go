@@ -6,10 +6,23 @@ type CreateItemDTO struct {
 	Name        string
 	ContainerID string
 	Size        int64
+	ContentType string
 	ChunkCount  int8
+	Compression item_model.Compression
+	Encryption  item_model.Encryption
+	KeyID       string
+	DataKey     []byte
+	Metadata    item_model.Metadata
+	// Versioned makes item the next version of items with the same name.
+	Versioned bool
 }
 
 type UpdateItemDTO struct {
-	Status     *item_model.Status
-	ChunkCount *uint8
+	Name        *string
+	ContainerID *string
+	Metadata    *item_model.Metadata
+	Status      *item_model.Status
+	ChunkCount  *uint8
+	StoredSize  *int64
+	Hash        *string
 }
@@ -2,7 +2,9 @@ package item_service
 
 import (
 	"context"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	"github.com/gofrs/uuid/v5"
 	log "github.com/sirupsen/logrus"
 	"time"
@@ -45,13 +47,19 @@ func (s Service) Create(ctx context.Context, dto CreateItemDTO) (item_model.Item
 		ID:          newID.String(),
 		Name:        dto.Name,
 		Size:        dto.Size,
+		ContentType: dto.ContentType,
 		ContainerID: dto.ContainerID,
 		Status:      item_model.ItemStatusPending,
+		Compression: dto.Compression,
+		Encryption:  dto.Encryption,
+		KeyID:       dto.KeyID,
+		DataKey:     dto.DataKey,
+		Metadata:    dto.Metadata,
 		Created:     now,
 		Modified:    now,
 	}
 
-	err = s.storage.Create(ctx, newItem)
+	newItem.Version, err = s.storage.Create(ctx, newItem, dto.Versioned)
 	if err != nil {
 		return item_model.Item{}, err
 	}
@@ -59,6 +67,35 @@ func (s Service) Create(ctx context.Context, dto CreateItemDTO) (item_model.Item
 	return newItem, nil
 }
 
+// CreateDeleteMarker creates version marking versioned item deleted and returns it.
+func (s Service) CreateDeleteMarker(ctx context.Context, itm item_model.Item) (item_model.Item, error) {
+	newID, err := uuid.NewV7()
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	now := time.Now()
+
+	marker := item_model.Item{
+		ID:           newID.String(),
+		Name:         itm.Name,
+		ContainerID:  itm.ContainerID,
+		Status:       item_model.ItemStatusOK,
+		Compression:  item_model.CompressionNone,
+		Encryption:   item_model.EncryptionNone,
+		DeleteMarker: true,
+		Created:      now,
+		Modified:     now,
+	}
+
+	marker.Version, err = s.storage.Create(ctx, marker, true)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	return marker, nil
+}
+
 // List return all item entities.
 func (s Service) List(ctx context.Context, containerID string) ([]item_model.Item, error) {
 	items, err := s.storage.List(ctx, containerID)
@@ -69,10 +106,41 @@ func (s Service) List(ctx context.Context, containerID string) ([]item_model.Ite
 	return items, nil
 }
 
+// ListByName returns items of the container with the name, the latest ones first.
+func (s Service) ListByName(ctx context.Context, containerID, name string) ([]item_model.Item, error) {
+	items, err := s.storage.ListByName(ctx, containerID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ListPage returns a page of container items matching the filter and total number of them.
+// One item more than the limit is returned if there is the next page.
+func (s Service) ListPage(ctx context.Context, containerID string, filter item_model.ListFilter, q page_model.Query) ([]item_model.Item, int, error) {
+	return s.storage.ListPage(ctx, containerID, filter, q)
+}
+
 // Update updates specified fields of an item.
 func (s Service) Update(ctx context.Context, itm item_model.Item, params UpdateItemDTO) (item_model.Item, error) {
 	var isChanged bool
 
+	if params.Name != nil {
+		isChanged = true
+		itm.Name = *params.Name
+	}
+
+	if params.ContainerID != nil {
+		isChanged = true
+		itm.ContainerID = *params.ContainerID
+	}
+
+	if params.Metadata != nil {
+		isChanged = true
+		itm.Metadata = *params.Metadata
+	}
+
 	if params.Status != nil {
 		isChanged = true
 		itm.Status = *params.Status
@@ -83,6 +151,16 @@ func (s Service) Update(ctx context.Context, itm item_model.Item, params UpdateI
 		itm.ChunkCount = *params.ChunkCount
 	}
 
+	if params.StoredSize != nil {
+		isChanged = true
+		itm.StoredSize = *params.StoredSize
+	}
+
+	if params.Hash != nil {
+		isChanged = true
+		itm.Hash = *params.Hash
+	}
+
 	if !isChanged {
 		return itm, nil
 	}
@@ -95,7 +173,83 @@ func (s Service) Update(ctx context.Context, itm item_model.Item, params UpdateI
 	return itm, nil
 }
 
+// UpdateDataKey replaces wrapped data key of an item.
+func (s Service) UpdateDataKey(ctx context.Context, id, keyID string, dataKey []byte) error {
+	return s.storage.UpdateDataKey(ctx, id, keyID, dataKey)
+}
+
+// Copy creates item with the name in the container, sharing content and chunk files of the source item.
+// Chunks of the source are given, new chunks refer to the same files. Versioned copy is the next version
+// of items with the same name.
+func (s Service) Copy(ctx context.Context, src item_model.Item, containerID, name string, chunks []chunk_model.Chunk, versioned bool) (item_model.Item, error) {
+	newID, err := uuid.NewV7()
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	now := time.Now()
+
+	newItem := src
+	newItem.ID = newID.String()
+	newItem.Name = name
+	newItem.ContainerID = containerID
+	newItem.Created = now
+	newItem.Modified = now
+
+	newChunks := make([]chunk_model.Chunk, 0, len(chunks))
+
+	for _, chunk := range chunks {
+		chunkID, err := uuid.NewV7()
+		if err != nil {
+			return item_model.Item{}, err
+		}
+
+		chunk.ID = chunkID.String()
+		chunk.ItemID = newItem.ID
+		chunk.Created = now
+		chunk.Modified = now
+
+		newChunks = append(newChunks, chunk)
+	}
+
+	if newItem.Version, err = s.storage.CreateCopy(ctx, newItem, newChunks, versioned); err != nil {
+		return item_model.Item{}, err
+	}
+
+	return newItem, nil
+}
+
+// ListWrappedWithOtherKey returns encrypted items whose data keys are wrapped with a master key other than specified.
+func (s Service) ListWrappedWithOtherKey(ctx context.Context, keyID string) ([]item_model.Item, error) {
+	items, err := s.storage.ListWrappedWithOtherKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Delete deletes item with its chunk models. Chunk files are queued to be removed from file servers.
 func (s Service) Delete(ctx context.Context, id string) error {
-	// TODO implement.
-	return nil
+	return s.storage.Delete(ctx, id)
+}
+
+// Trash moves item to trash, its chunks are kept until it's deleted.
+func (s Service) Trash(ctx context.Context, id string) error {
+	return s.storage.Trash(ctx, id, time.Now())
+}
+
+// Restore takes item out of trash.
+func (s Service) Restore(ctx context.Context, id string) error {
+	return s.storage.Restore(ctx, id)
+}
+
+// ListTrashed returns items moved to trash before the time.
+func (s Service) ListTrashed(ctx context.Context, before time.Time) ([]item_model.Item, error) {
+	return s.storage.ListTrashed(ctx, before)
+}
+
+// DeleteTrashed deletes item moved to trash before the time, like Delete.
+func (s Service) DeleteTrashed(ctx context.Context, id string, before time.Time) error {
+	return s.storage.DeleteTrashed(ctx, id, before)
 }
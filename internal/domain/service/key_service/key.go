@@ -0,0 +1,131 @@
+package key_service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"github.com/PavelKhripkov/object_storage/internal/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+)
+
+// DataKeySize is a length of generated data keys, bytes.
+const DataKeySize = 32
+
+var (
+	ErrNoMasterKey      = errors.New("no master key configured")
+	ErrUnknownMasterKey = errors.New("unknown master key")
+)
+
+// Service provides methods to generate item data keys and to wrap them with master keys.
+// Master keys are wrapping data keys with AES-256-GCM.
+type Service struct {
+	active string
+	keys   map[string]cipher.AEAD
+	l      *log.Entry
+}
+
+// NewKeyService creates new key service. The first of master keys is used to wrap new data keys.
+func NewKeyService(masterKeys []config.MasterKey, l *log.Logger) (*Service, error) {
+	res := &Service{
+		keys: make(map[string]cipher.AEAD, len(masterKeys)),
+		l:    l.WithField("component", "KeyService"),
+	}
+
+	for i, key := range masterKeys {
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			res.active = key.ID
+		}
+		res.keys[key.ID] = aead
+	}
+
+	return res, nil
+}
+
+// Enabled reports whether master key is configured, so data keys can be generated.
+func (s *Service) Enabled() bool {
+	return s.active != ""
+}
+
+// ActiveKeyID returns ID of the master key wrapping new data keys.
+func (s *Service) ActiveKeyID() string {
+	return s.active
+}
+
+// GenerateDataKey returns new random data key, the same key wrapped with active master key and ID of the master key.
+func (s *Service) GenerateDataKey() ([]byte, []byte, string, error) {
+	if !s.Enabled() {
+		return nil, nil, "", ErrNoMasterKey
+	}
+
+	dataKey := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, "", err
+	}
+
+	wrapped, err := s.wrap(s.active, dataKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return dataKey, wrapped, s.active, nil
+}
+
+// UnwrapDataKey decrypts data key wrapped with specified master key.
+func (s *Service) UnwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := s.keys[keyID]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownMasterKey, "key id %q", keyID)
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is too short")
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
+
+// RewrapDataKey unwraps data key and wraps it again with active master key.
+// Returns new wrapped key and ID of the master key.
+func (s *Service) RewrapDataKey(keyID string, wrapped []byte) ([]byte, string, error) {
+	if !s.Enabled() {
+		return nil, "", ErrNoMasterKey
+	}
+
+	dataKey, err := s.UnwrapDataKey(keyID, wrapped)
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := s.wrap(s.active, dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return res, s.active, nil
+}
+
+// wrap encrypts data key with specified master key. Random nonce is prepended to the result.
+func (s *Service) wrap(keyID string, dataKey []byte) ([]byte, error) {
+	aead := s.keys[keyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
@@ -0,0 +1,100 @@
+package presign_service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureParam is a query parameter carrying URL signature.
+	SignatureParam = "signature"
+	// ExpiresParam is a query parameter carrying URL expiry, unix seconds.
+	ExpiresParam = "expires"
+)
+
+var (
+	ErrDisabled         = errors.New("presigned URLs are disabled")
+	ErrInvalidSignature = errors.New("invalid URL signature")
+	ErrExpired          = errors.New("URL is expired")
+)
+
+// Service signs URLs with HMAC-SHA256, so they can be used without other credentials until they expire.
+// Signature covers request method, path and all query parameters.
+type Service struct {
+	key []byte
+	l   *log.Entry
+}
+
+// NewPresignService creates new presign service. Empty key disables signing and verification.
+func NewPresignService(key []byte, l *log.Logger) *Service {
+	return &Service{
+		key: key,
+		l:   l.WithField("component", "PresignService"),
+	}
+}
+
+// Enabled reports whether signing key is configured.
+func (s *Service) Enabled() bool {
+	return len(s.key) > 0
+}
+
+// Sign returns query parameters of URL allowing the method on the path until expiry.
+func (s *Service) Sign(method, path string, params url.Values, expires time.Time) (url.Values, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	res := make(url.Values, len(params)+2)
+	for k, v := range params {
+		res[k] = append([]string(nil), v...)
+	}
+
+	res.Set(ExpiresParam, strconv.FormatInt(expires.Unix(), 10))
+	res.Set(SignatureParam, s.signature(method, path, res))
+
+	return res, nil
+}
+
+// Verify checks that query parameters are signed for the method on the path and aren't expired.
+func (s *Service) Verify(method, path string, params url.Values) error {
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+
+	signature := params.Get(SignatureParam)
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(s.signature(method, path, params))) {
+		return ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(params.Get(ExpiresParam), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// signature calculates signature of the method, path and query parameters, except signature itself.
+func (s *Service) signature(method, path string, params url.Values) string {
+	signed := make(url.Values, len(params))
+	for k, v := range params {
+		if k != SignatureParam {
+			signed[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(method + "\n" + path + "\n" + signed.Encode()))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
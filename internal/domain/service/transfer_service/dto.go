@@ -0,0 +1,17 @@
+package transfer_service
+
+// Stats represents current state of chunk transfers.
+type Stats struct {
+	MaxActive    int                 `json:"max_active"`
+	MaxPerServer int                 `json:"max_per_server"`
+	Active       int                 `json:"active"`
+	Queued       int                 `json:"queued"`
+	FileServers  map[string]Counters `json:"file_servers"`
+	Items        map[string]Counters `json:"items"`
+}
+
+// Counters represents number of active and queued transfers.
+type Counters struct {
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+}
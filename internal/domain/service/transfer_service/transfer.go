@@ -0,0 +1,227 @@
+package transfer_service
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+// Service schedules chunk transfers of all items.
+// It limits number of concurrent transfers, both in total and per file server,
+// and shares transfer slots fairly: the item with the fewest active transfers goes first.
+type Service struct {
+	maxActive    int
+	maxPerServer int
+
+	mu              sync.Mutex
+	queues          map[string][]*ticket
+	order           []string
+	active          int
+	activePerServer map[string]int
+	activePerItem   map[string]int
+
+	l *log.Entry
+}
+
+// ticket represents a transfer waiting for a slot.
+type ticket struct {
+	itemID       string
+	fileServerID string
+	ready        chan struct{}
+	granted      bool
+}
+
+// NewTransferService creates new transfer service. Non-positive limits mean no limit.
+func NewTransferService(maxActive, maxPerServer int, l *log.Logger) *Service {
+	return &Service{
+		maxActive:       maxActive,
+		maxPerServer:    maxPerServer,
+		queues:          make(map[string][]*ticket),
+		activePerServer: make(map[string]int),
+		activePerItem:   make(map[string]int),
+		l:               l.WithField("component", "TransferService"),
+	}
+}
+
+// Acquire blocks until transfer of an item chunk to the file server can be started or context is done.
+// Returned function must be called once transfer is finished.
+func (s *Service) Acquire(ctx context.Context, itemID, fileServerID string) (func(), error) {
+	t := &ticket{
+		itemID:       itemID,
+		fileServerID: fileServerID,
+		ready:        make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if len(s.queues[itemID]) == 0 {
+		s.order = append(s.order, itemID)
+	}
+	s.queues[itemID] = append(s.queues[itemID], t)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-t.ready:
+		return s.releaseFunc(t), nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Slot might be granted while context was being cancelled.
+	if t.granted {
+		s.release(t)
+	} else {
+		s.remove(t)
+	}
+
+	return nil, ctx.Err()
+}
+
+// Stats returns current state of transfers.
+func (s *Service) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := Stats{
+		MaxActive:    s.maxActive,
+		MaxPerServer: s.maxPerServer,
+		Active:       s.active,
+		FileServers:  make(map[string]Counters),
+		Items:        make(map[string]Counters),
+	}
+
+	for id, count := range s.activePerServer {
+		res.FileServers[id] = Counters{Active: count}
+	}
+
+	for id, count := range s.activePerItem {
+		res.Items[id] = Counters{Active: count}
+	}
+
+	for itemID, queue := range s.queues {
+		res.Queued += len(queue)
+
+		item := res.Items[itemID]
+		item.Queued += len(queue)
+		res.Items[itemID] = item
+
+		for _, t := range queue {
+			server := res.FileServers[t.fileServerID]
+			server.Queued++
+			res.FileServers[t.fileServerID] = server
+		}
+	}
+
+	return res
+}
+
+func (s *Service) releaseFunc(t *ticket) func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.release(t)
+		})
+	}
+}
+
+// release frees slot of a granted ticket and passes it to waiting ones. Must be called under lock.
+func (s *Service) release(t *ticket) {
+	s.active--
+
+	s.activePerServer[t.fileServerID]--
+	if s.activePerServer[t.fileServerID] == 0 {
+		delete(s.activePerServer, t.fileServerID)
+	}
+
+	s.activePerItem[t.itemID]--
+	if s.activePerItem[t.itemID] == 0 {
+		delete(s.activePerItem, t.itemID)
+	}
+
+	s.dispatch()
+}
+
+// remove removes waiting ticket from the queue. Must be called under lock.
+func (s *Service) remove(t *ticket) {
+	queue := s.queues[t.itemID]
+
+	for i, queued := range queue {
+		if queued == t {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	s.setQueue(t.itemID, queue)
+}
+
+// dispatch grants slots to waiting tickets while limits allow. Must be called under lock.
+// Among items having a ticket that can be started, the one with the fewest active transfers is chosen,
+// ties are resolved in order of waiting.
+func (s *Service) dispatch() {
+	for s.maxActive <= 0 || s.active < s.maxActive {
+		bestOrder, bestTicket := -1, -1
+
+		for i, itemID := range s.order {
+			if bestOrder >= 0 && s.activePerItem[itemID] >= s.activePerItem[s.order[bestOrder]] {
+				continue
+			}
+
+			for j, t := range s.queues[itemID] {
+				if s.maxPerServer <= 0 || s.activePerServer[t.fileServerID] < s.maxPerServer {
+					bestOrder, bestTicket = i, j
+					break
+				}
+			}
+		}
+
+		if bestOrder < 0 {
+			return
+		}
+
+		itemID := s.order[bestOrder]
+		queue := s.queues[itemID]
+		t := queue[bestTicket]
+
+		s.setQueue(itemID, append(queue[:bestTicket], queue[bestTicket+1:]...))
+
+		// The item goes to the end of the line.
+		if len(s.queues[itemID]) > 0 {
+			s.removeFromOrder(itemID)
+			s.order = append(s.order, itemID)
+		}
+
+		s.active++
+		s.activePerServer[t.fileServerID]++
+		s.activePerItem[t.itemID]++
+
+		t.granted = true
+		close(t.ready)
+	}
+}
+
+// setQueue replaces queue of an item, forgetting the item if queue is empty. Must be called under lock.
+func (s *Service) setQueue(itemID string, queue []*ticket) {
+	if len(queue) > 0 {
+		s.queues[itemID] = queue
+		return
+	}
+
+	delete(s.queues, itemID)
+	s.removeFromOrder(itemID)
+}
+
+func (s *Service) removeFromOrder(itemID string) {
+	for i, id := range s.order {
+		if id == itemID {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
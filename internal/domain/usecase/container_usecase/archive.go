@@ -0,0 +1,259 @@
+package container_usecase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/pkg/errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat specifies format of container archive.
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip   ArchiveFormat = "zip"
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+)
+
+// Validate checks that archive format is supported.
+func (s ArchiveFormat) Validate() error {
+	switch s {
+	case ArchiveFormatZip, ArchiveFormatTarGz:
+		return nil
+	default:
+		return errors.Errorf("unknown archive format: %q", s)
+	}
+}
+
+// archiveWriter writes entries of an archive.
+type archiveWriter interface {
+	// Dir adds directory entry.
+	Dir(name string, modified time.Time) error
+	// File adds file entry and returns writer of its content.
+	File(name string, size int64, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+// archiveEntry is a container to be put into archive under the directory.
+type archiveEntry struct {
+	container container_model.Container
+	dir       string
+}
+
+// Archive streams items of the container into archive of specified format. Items are read with item downloads,
+// nothing is staged on disk. If recursive, child containers are archived as subdirectories.
+// Items which can't be downloaded, e.g. failed ones or pending ones uploaded to another server, are skipped.
+// Items are read at rate at most, if it's positive, and within their container limits.
+func (s *Usecase) Archive(ctx context.Context, root container_model.Container, format ArchiveFormat, recursive bool, rate int64, w io.Writer) error {
+	var containers []container_model.Container
+
+	if recursive {
+		var err error
+
+		containers, err = s.containerService.List(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	aw, err := newArchiveWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	queue := []archiveEntry{{container: root, dir: archiveName(root.Name, root.ID)}}
+	visited := map[string]bool{root.ID: true}
+
+	for len(queue) > 0 {
+		entry := queue[0]
+		queue = queue[1:]
+
+		if err = aw.Dir(entry.dir, entry.container.Modified); err != nil {
+			return err
+		}
+
+		// Items and child containers share names within a directory.
+		used := make(map[string]bool)
+
+		if err = s.archiveItems(ctx, aw, entry, used, rate); err != nil {
+			return err
+		}
+
+		for _, child := range containers {
+			if child.ParentID != entry.container.ID || child.Trashed != nil || visited[child.ID] {
+				continue
+			}
+
+			visited[child.ID] = true
+			queue = append(queue, archiveEntry{
+				container: child,
+				dir:       path.Join(entry.dir, uniqueName(used, archiveName(child.Name, child.ID))),
+			})
+		}
+	}
+
+	return aw.Close()
+}
+
+// archiveItems writes items of the container into the archive directory. Names taken in the directory are marked in used.
+func (s *Usecase) archiveItems(ctx context.Context, aw archiveWriter, entry archiveEntry, used map[string]bool, rate int64) error {
+	items, err := s.itemUsecase.List(ctx, entry.container.ID)
+	if err != nil {
+		return err
+	}
+
+	// Only the latest versions are archived.
+	items = item_model.Current(items)
+
+	for _, itm := range items {
+		if itm.Status == item_model.ItemStatusFail {
+			s.l.Infof("Item %s isn't archived, status: %s.", itm.ID, itm.Status)
+			continue
+		}
+
+		content, contentInfo, err := s.itemUsecase.Download(ctx, itm.ID, rate)
+		if err != nil {
+			s.l.WithError(err).Warnf("Item %s isn't archived.", itm.ID)
+			continue
+		}
+
+		name := path.Join(entry.dir, uniqueName(used, archiveName(contentInfo.Name, itm.ID)))
+
+		err = s.archiveItem(aw, name, content, contentInfo.Size, contentInfo.Modified)
+
+		if err := content.Close(); err != nil {
+			s.l.Error(err)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "item %s", itm.ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Usecase) archiveItem(aw archiveWriter, name string, content io.Reader, size int64, modified time.Time) error {
+	dst, err := aw.File(name, size, modified)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(dst, content)
+	if err != nil {
+		return err
+	}
+
+	if written != size {
+		return errors.Errorf("item read partially: %d of %d bytes", written, size)
+	}
+
+	return nil
+}
+
+// archiveName makes name safe to be used as archive path element.
+func archiveName(name, id string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+
+	if name == "" || name == "." || name == ".." {
+		return id
+	}
+
+	return name
+}
+
+// uniqueName returns name not used in the directory yet, adding a number to the name if needed.
+func uniqueName(used map[string]bool, name string) string {
+	res := name
+
+	for i := 2; used[res]; i++ {
+		ext := path.Ext(name)
+		res = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+
+	used[res] = true
+
+	return res
+}
+
+func newArchiveWriter(format ArchiveFormat, w io.Writer) (archiveWriter, error) {
+	switch format {
+	case ArchiveFormatZip:
+		return zipWriter{w: zip.NewWriter(w)}, nil
+	case ArchiveFormatTarGz:
+		gw := gzip.NewWriter(w)
+		return tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}, nil
+	default:
+		return nil, errors.Errorf("unknown archive format: %q", format)
+	}
+}
+
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func (s zipWriter) Dir(name string, modified time.Time) error {
+	_, err := s.w.CreateHeader(&zip.FileHeader{
+		Name:     name + "/",
+		Modified: modified,
+	})
+
+	return err
+}
+
+func (s zipWriter) File(name string, _ int64, modified time.Time) (io.Writer, error) {
+	return s.w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+func (s zipWriter) Close() error {
+	return s.w.Close()
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func (s tarGzWriter) Dir(name string, modified time.Time) error {
+	return s.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0o755,
+		ModTime:  modified,
+	})
+}
+
+func (s tarGzWriter) File(name string, size int64, modified time.Time) (io.Writer, error) {
+	err := s.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modified,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tw, nil
+}
+
+func (s tarGzWriter) Close() error {
+	if err := s.tw.Close(); err != nil {
+		return err
+	}
+
+	return s.gw.Close()
+}
@@ -2,39 +2,85 @@ package container_usecase
 
 import (
 	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/container_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
 )
 
 // Usecase represents container use cases.
 type Usecase struct {
 	containerService *container_service.Service
-	l                *log.Entry
+	itemUsecase      *item_usecase.Usecase
+	deletions        *deletionJobs
+	// trashRetention is a time deleted items and containers are kept in trash for.
+	trashRetention time.Duration
+	l              *log.Entry
 }
 
 // NewContainerUsecase creates new container use cases service.
-func NewContainerUsecase(containerService *container_service.Service, l *log.Logger) *Usecase {
+func NewContainerUsecase(containerService *container_service.Service, itemUsecase *item_usecase.Usecase, trashRetention time.Duration, l *log.Logger) *Usecase {
 	return &Usecase{
 		containerService: containerService,
+		itemUsecase:      itemUsecase,
+		deletions:        &deletionJobs{jobs: make(map[string]*DeletionJob)},
+		trashRetention:   trashRetention,
 		l:                l.WithField("component", "ContainerUsecase"),
 	}
 }
 
+// Get returns container, ErrContainerNotFound if there is no such one or it's in trash.
 func (s *Usecase) Get(ctx context.Context, id string) (container_model.Container, error) {
 	res, err := s.containerService.Get(ctx, id)
 	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return container_model.Container{}, ErrContainerNotFound
+		}
 		return container_model.Container{}, err
 	}
 
+	if res.Trashed != nil {
+		return container_model.Container{}, ErrContainerNotFound
+	}
+
 	return res, nil
 }
 
 func (s *Usecase) Create(ctx context.Context, dto CreateContainerDTO) (container_model.Container, error) {
+	if dto.Compression == "" {
+		dto.Compression = item_model.CompressionNone
+	}
+
+	if err := dto.Compression.Validate(); err != nil {
+		return container_model.Container{}, err
+	}
+
+	if dto.RateLimit < 0 {
+		return container_model.Container{}, errors.New("rate limit can't be negative")
+	}
+
+	// Empty encryption means gateway default is used.
+	if dto.Encryption != "" {
+		if err := dto.Encryption.Validate(); err != nil {
+			return container_model.Container{}, err
+		}
+	}
+
 	params := container_service.CreateContainerDTO{
-		Name:        dto.Name,
-		Description: dto.Description,
-		ParentID:    dto.ParentID,
+		Name:         dto.Name,
+		Description:  dto.Description,
+		ParentID:     dto.ParentID,
+		Compression:  dto.Compression,
+		Encryption:   dto.Encryption,
+		CacheControl: dto.CacheControl,
+		RateLimit:    dto.RateLimit,
+		Versioning:   dto.Versioning,
 	}
 
 	entity, err := s.containerService.Create(ctx, params)
@@ -45,6 +91,49 @@ func (s *Usecase) Create(ctx context.Context, dto CreateContainerDTO) (container
 	return entity, nil
 }
 
+// ListPage returns a page of containers, sorted by name or creation time. Either containers in trash
+// or other ones are listed.
+func (s *Usecase) ListPage(ctx context.Context, trashed bool, params page_model.Params) (ContainerPage, error) {
+	q, err := params.Query(page_model.SortName, page_model.SortCreated)
+	if err != nil {
+		return ContainerPage{}, err
+	}
+
+	containers, total, err := s.containerService.ListPage(ctx, trashed, q)
+	if err != nil {
+		return ContainerPage{}, err
+	}
+
+	res := ContainerPage{Containers: containers, Total: total}
+
+	if len(containers) > q.Limit {
+		res.Containers = containers[:q.Limit]
+		last := res.Containers[q.Limit-1]
+
+		key := last.Name
+		if q.Sort == page_model.SortCreated {
+			key = strconv.FormatInt(last.Created.UnixMilli(), 10)
+		}
+
+		res.Next = q.Next(key, last.ID).Encode()
+	}
+
+	return res, nil
+}
+
+// ListItems returns a page of container items matching the filter.
+func (s *Usecase) ListItems(ctx context.Context, id string, filter item_model.ListFilter, params page_model.Params) (item_usecase.ItemPage, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return item_usecase.ItemPage{}, err
+	}
+
+	return s.itemUsecase.ListPage(ctx, item_usecase.ListItemsDTO{
+		ContainerID: id,
+		Filter:      filter,
+		Params:      params,
+	})
+}
+
 func (s *Usecase) List(ctx context.Context) ([]container_model.Container, error) {
 	res, err := s.containerService.List(ctx)
 	if err != nil {
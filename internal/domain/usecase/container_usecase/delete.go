@@ -0,0 +1,242 @@
+package container_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrContainerNotFound = errors.New("container not found")
+	ErrContainerNotEmpty = errors.New("container isn't empty")
+)
+
+// deletionRetention is a time finished deletion job is kept for.
+const deletionRetention = 10 * time.Minute
+
+type DeletionStatus string
+
+const (
+	DeletionStatusRunning DeletionStatus = "running"
+	DeletionStatusDone    DeletionStatus = "done"
+	DeletionStatusFailed  DeletionStatus = "failed"
+)
+
+// DeletionJob represents state of recursive deletion of a container.
+type DeletionJob struct {
+	ContainerID       string         `json:"container_id"`
+	Status            DeletionStatus `json:"status"`
+	ContainersTotal   int            `json:"containers_total"`
+	ContainersDeleted int            `json:"containers_deleted"`
+	ItemsDeleted      int            `json:"items_deleted"`
+	Error             string         `json:"error,omitempty"`
+	Started           time.Time      `json:"started"`
+	Finished          *time.Time     `json:"finished,omitempty"`
+
+	// purgeBefore limits jobs purging trash to entities moved to trash before the time. Zero means no limit.
+	purgeBefore time.Time
+}
+
+// deletionJobs keeps deletion jobs run by this instance, by container ID.
+type deletionJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*DeletionJob
+}
+
+// Delete deletes empty container.
+func (s *Usecase) Delete(ctx context.Context, id string) error {
+	err := s.containerService.Delete(ctx, id)
+
+	switch {
+	case errors.Is(err, sqlite.ErrNotFound):
+		return ErrContainerNotFound
+	case errors.Is(err, sqlite.ErrNotEmpty):
+		return ErrContainerNotEmpty
+	default:
+		return err
+	}
+}
+
+// DeleteRecursive starts background job deleting container with all its child containers and items.
+// Returns running job if the container is being deleted already. Containers are deleted after their content,
+// so job failed or interrupted by restart leaves consistent tree behind and can be started again.
+func (s *Usecase) DeleteRecursive(ctx context.Context, id string) (DeletionJob, error) {
+	return s.startDeletion(ctx, id, time.Time{})
+}
+
+// startDeletion starts background job deleting container tree. Job started with non-zero purgeBefore deletes
+// only containers and items moved to trash before the time, so content restored meanwhile is kept.
+func (s *Usecase) startDeletion(ctx context.Context, id string, purgeBefore time.Time) (DeletionJob, error) {
+	if _, err := s.containerService.Get(ctx, id); err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return DeletionJob{}, ErrContainerNotFound
+		}
+		return DeletionJob{}, err
+	}
+
+	s.deletions.mu.Lock()
+	defer s.deletions.mu.Unlock()
+
+	if job, ok := s.deletions.jobs[id]; ok && job.Status == DeletionStatusRunning {
+		return *job, nil
+	}
+
+	job := &DeletionJob{
+		ContainerID: id,
+		Status:      DeletionStatusRunning,
+		Started:     time.Now(),
+		purgeBefore: purgeBefore,
+	}
+	s.deletions.jobs[id] = job
+
+	// Job outlives the request.
+	go s.runDeletion(context.Background(), job)
+
+	return *job, nil
+}
+
+// DeletionJob returns state of the latest deletion job of the container.
+func (s *Usecase) DeletionJob(id string) (DeletionJob, bool) {
+	s.deletions.mu.Lock()
+	defer s.deletions.mu.Unlock()
+
+	job, ok := s.deletions.jobs[id]
+	if !ok {
+		return DeletionJob{}, false
+	}
+
+	res := *job
+	return res, true
+}
+
+// runDeletion deletes container tree and sets final status of the job. Job is forgotten after retention period.
+func (s *Usecase) runDeletion(ctx context.Context, job *DeletionJob) {
+	err := s.deleteTree(ctx, job)
+
+	s.updateDeletion(func() {
+		now := time.Now()
+		job.Finished = &now
+		job.Status = DeletionStatusDone
+
+		if err != nil {
+			job.Status = DeletionStatusFailed
+			job.Error = err.Error()
+		}
+	})
+
+	if err != nil {
+		s.l.WithError(err).Errorf("Deletion of container %s failed.", job.ContainerID)
+	} else {
+		s.l.Infof("Container %s deleted with %d containers and %d items.", job.ContainerID, job.ContainersDeleted-1, job.ItemsDeleted)
+	}
+
+	time.AfterFunc(deletionRetention, func() {
+		s.deletions.mu.Lock()
+		defer s.deletions.mu.Unlock()
+
+		if s.deletions.jobs[job.ContainerID] == job {
+			delete(s.deletions.jobs, job.ContainerID)
+		}
+	})
+}
+
+// deleteTree deletes items of the container tree, then containers themselves, the deepest ones first.
+func (s *Usecase) deleteTree(ctx context.Context, job *DeletionJob) error {
+	containers, err := s.containerService.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tree := subtree(containers, job.ContainerID)
+
+	s.updateDeletion(func() {
+		job.ContainersTotal = len(tree)
+	})
+
+	for i := len(tree) - 1; i >= 0; i-- {
+		if err = s.deleteItems(ctx, job, tree[i]); err != nil {
+			return err
+		}
+
+		if job.purgeBefore.IsZero() {
+			err = s.containerService.Delete(ctx, tree[i])
+		} else {
+			err = s.containerService.DeleteTrashed(ctx, tree[i], job.purgeBefore)
+		}
+
+		if errors.Is(err, sqlite.ErrNotEmpty) {
+			return errors.Errorf("container %s got new content while being deleted", tree[i])
+		}
+
+		// Container deleted or restored concurrently is fine.
+		if err != nil && !errors.Is(err, sqlite.ErrNotFound) {
+			return err
+		}
+
+		s.updateDeletion(func() {
+			job.ContainersDeleted++
+		})
+	}
+
+	return nil
+}
+
+// deleteItems deletes all items of the container, only ones moved to trash long enough if the job purges trash.
+func (s *Usecase) deleteItems(ctx context.Context, job *DeletionJob, containerID string) error {
+	items, err := s.itemUsecase.List(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	for _, itm := range items {
+		if job.purgeBefore.IsZero() {
+			err = s.itemUsecase.Delete(ctx, itm.ID)
+		} else {
+			err = s.itemUsecase.DeleteTrashed(ctx, itm.ID, job.purgeBefore)
+		}
+
+		if errors.Is(err, item_usecase.ErrItemNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "item %s", itm.ID)
+		}
+
+		s.updateDeletion(func() {
+			job.ItemsDeleted++
+		})
+	}
+
+	return nil
+}
+
+func (s *Usecase) updateDeletion(f func()) {
+	s.deletions.mu.Lock()
+	defer s.deletions.mu.Unlock()
+
+	f()
+}
+
+// subtree returns IDs of the root and all its descendants, parents go before their children.
+func subtree(containers []container_model.Container, rootID string) []string {
+	res := []string{rootID}
+	visited := map[string]bool{rootID: true}
+
+	for i := 0; i < len(res); i++ {
+		for _, c := range containers {
+			if c.ParentID != res[i] || visited[c.ID] {
+				continue
+			}
+
+			visited[c.ID] = true
+			res = append(res, c.ID)
+		}
+	}
+
+	return res
+}
@@ -1,7 +1,33 @@
 package container_usecase
 
+import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+)
+
 type CreateContainerDTO struct {
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	ParentID    string `json:"parent_id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	ParentID     string                 `json:"parent_id,omitempty"`
+	Compression  item_model.Compression `json:"compression,omitempty"`
+	Encryption   item_model.Encryption  `json:"encryption,omitempty"`
+	CacheControl string                 `json:"cache_control,omitempty"`
+	RateLimit    int64                  `json:"rate_limit,omitempty"`
+	Versioning   bool                   `json:"versioning,omitempty"`
+}
+
+// UpdateContainerDTO specifies container fields to be changed, nil fields are kept.
+// Versioning can be turned on only.
+type UpdateContainerDTO struct {
+	Name       *string `json:"name,omitempty"`
+	ParentID   *string `json:"parent_id,omitempty"`
+	Versioning *bool   `json:"versioning,omitempty"`
+}
+
+// ContainerPage is a page of containers. Next is a cursor of the next page, empty on the last one.
+// Total is a number of containers matching the filters on all pages.
+type ContainerPage struct {
+	Containers []container_model.Container `json:"containers"`
+	Total      int                         `json:"total"`
+	Next       string                      `json:"next,omitempty"`
 }
@@ -0,0 +1,92 @@
+package container_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+var (
+	ErrParentNotFound  = errors.New("parent container not found")
+	ErrContainerExists = errors.New("container with the name exists in the parent")
+	ErrContainerCycle  = errors.New("container can't be moved into itself or its descendant")
+	ErrInvalidName     = errors.New("invalid container name")
+	ErrVersioningOn    = errors.New("versioning can't be turned off")
+)
+
+// Update renames container or moves it to another parent, empty parent ID makes it a root container.
+// Container can't be moved into its own subtree. Versioning can be turned on, but not off.
+func (s *Usecase) Update(ctx context.Context, id string, dto UpdateContainerDTO) (container_model.Container, error) {
+	cont, err := s.Get(ctx, id)
+	if err != nil {
+		return container_model.Container{}, err
+	}
+
+	if dto.Versioning != nil && !*dto.Versioning && cont.Versioning {
+		return container_model.Container{}, ErrVersioningOn
+	}
+
+	if dto.Name != nil || dto.ParentID != nil {
+		if cont, err = s.move(ctx, cont, dto); err != nil {
+			return container_model.Container{}, err
+		}
+	}
+
+	if dto.Versioning != nil && *dto.Versioning && !cont.Versioning {
+		cont, err = s.containerService.EnableVersioning(ctx, cont)
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return container_model.Container{}, ErrContainerNotFound
+		}
+	}
+
+	return cont, err
+}
+
+// move renames container and moves it to another parent.
+func (s *Usecase) move(ctx context.Context, cont container_model.Container, dto UpdateContainerDTO) (container_model.Container, error) {
+	name, parentID := cont.Name, cont.ParentID
+
+	if dto.Name != nil {
+		name = *dto.Name
+	}
+
+	if dto.ParentID != nil {
+		parentID = *dto.ParentID
+	}
+
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return container_model.Container{}, errors.Wrapf(ErrInvalidName, "%q", name)
+	}
+
+	if parentID != "" && parentID != cont.ParentID {
+		if _, err := s.Get(ctx, parentID); err != nil {
+			if errors.Is(err, ErrContainerNotFound) {
+				return container_model.Container{}, ErrParentNotFound
+			}
+			return container_model.Container{}, err
+		}
+	}
+
+	existing, err := s.containerService.GetByName(ctx, parentID, name)
+	switch {
+	case err == nil && existing.ID != cont.ID:
+		return container_model.Container{}, ErrContainerExists
+	case err != nil && !errors.Is(err, sqlite.ErrNotFound):
+		return container_model.Container{}, err
+	}
+
+	res, err := s.containerService.Move(ctx, cont, parentID, name)
+
+	switch {
+	case errors.Is(err, sqlite.ErrCycle):
+		return container_model.Container{}, ErrContainerCycle
+	case errors.Is(err, sqlite.ErrNotFound):
+		return container_model.Container{}, ErrContainerNotFound
+	case err != nil:
+		return container_model.Container{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package container_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// purgeInterval is an interval trash is checked for entities kept longer than retention period with.
+const purgeInterval = 10 * time.Minute
+
+var (
+	ErrContainerNotTrashed = errors.New("container isn't deleted")
+	ErrParentTrashed       = errors.New("parent container is deleted, restore it first")
+	ErrContainerBusy       = errors.New("container has items being stored")
+)
+
+// Trash moves container to trash. Container with items or child containers is moved only if recursive is set,
+// together with its content then. Content is hidden from listings and downloads until the container is restored.
+// Like items, content being stored can't be moved to trash, nothing is moved then.
+func (s *Usecase) Trash(ctx context.Context, id string, recursive bool) error {
+	err := s.containerService.Trash(ctx, id, recursive)
+
+	switch {
+	case errors.Is(err, sqlite.ErrNotFound):
+		return ErrContainerNotFound
+	case errors.Is(err, sqlite.ErrNotEmpty):
+		return ErrContainerNotEmpty
+	case errors.Is(err, sqlite.ErrBusy):
+		return ErrContainerBusy
+	default:
+		return err
+	}
+}
+
+// Restore takes container out of trash, together with content moved to trash with it. Content deleted before
+// the container stays in trash. Container can't be restored into parent in trash, or next to a container
+// with the same name.
+func (s *Usecase) Restore(ctx context.Context, id string) (container_model.Container, error) {
+	cont, err := s.containerService.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return container_model.Container{}, ErrContainerNotFound
+		}
+		return container_model.Container{}, err
+	}
+
+	if cont.Trashed == nil {
+		return container_model.Container{}, ErrContainerNotTrashed
+	}
+
+	if cont.ParentID != "" {
+		if _, err = s.Get(ctx, cont.ParentID); err != nil {
+			if errors.Is(err, ErrContainerNotFound) {
+				return container_model.Container{}, ErrParentTrashed
+			}
+			return container_model.Container{}, err
+		}
+	}
+
+	_, err = s.containerService.GetByName(ctx, cont.ParentID, cont.Name)
+	switch {
+	case err == nil:
+		return container_model.Container{}, ErrContainerExists
+	case !errors.Is(err, sqlite.ErrNotFound):
+		return container_model.Container{}, err
+	}
+
+	err = s.containerService.Restore(ctx, id)
+	switch {
+	// Restored concurrently.
+	case errors.Is(err, sqlite.ErrNotFound):
+		return container_model.Container{}, ErrContainerNotTrashed
+	case err != nil:
+		return container_model.Container{}, err
+	}
+
+	cont.Trashed = nil
+
+	return cont, nil
+}
+
+// RunPurge deletes items and containers kept in trash longer than retention period permanently, until ctx is done.
+func (s *Usecase) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		s.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes items, then containers kept in trash longer than retention period. Containers are deleted
+// by recursive deletion jobs started for the topmost ones. Only entities still in trash are deleted,
+// so ones restored while purging are kept.
+func (s *Usecase) purge(ctx context.Context) {
+	before := time.Now().Add(-s.trashRetention)
+
+	purged, err := s.itemUsecase.PurgeTrash(ctx, before)
+	if err != nil {
+		s.l.WithError(err).Error("Purging items from trash failed.")
+	}
+
+	if purged > 0 {
+		s.l.Infof("%d items purged from trash.", purged)
+	}
+
+	containers, err := s.containerService.ListTrashed(ctx, before)
+	if err != nil {
+		s.l.WithError(err).Error("Purging containers from trash failed.")
+		return
+	}
+
+	expired := make(map[string]bool, len(containers))
+	for _, cont := range containers {
+		expired[cont.ID] = true
+	}
+
+	for _, cont := range containers {
+		if expired[cont.ParentID] {
+			continue
+		}
+
+		if _, err = s.startDeletion(ctx, cont.ID, before); err != nil && !errors.Is(err, ErrContainerNotFound) {
+			s.l.WithError(err).Errorf("Purging container %s from trash failed.", cont.ID)
+		}
+	}
+}
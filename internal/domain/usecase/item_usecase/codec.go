@@ -0,0 +1,123 @@
+package item_usecase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/pkg/frame"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"io"
+)
+
+// chunkCodec builds codec for a chunk of an item: compression is applied first, then encryption.
+// Returns nil codec if chunk is stored as is.
+func chunkCodec(itm item_model.Item, dataKey []byte, position uint8) (frame.Codec, error) {
+	var codecs []frame.Codec
+
+	compression, err := compressionCodec(itm.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	if compression != nil {
+		codecs = append(codecs, compression)
+	}
+
+	encryption, err := encryptionCodec(itm.Encryption, dataKey, position)
+	if err != nil {
+		return nil, err
+	}
+
+	if encryption != nil {
+		codecs = append(codecs, encryption)
+	}
+
+	if len(codecs) == 0 {
+		return nil, nil
+	}
+
+	return frame.Chain(codecs...), nil
+}
+
+// compressionCodec returns codec implementing compression algorithm.
+// Returns nil codec if chunks are stored uncompressed.
+func compressionCodec(compression item_model.Compression) (frame.Codec, error) {
+	switch compression {
+	case item_model.CompressionNone, "":
+		return nil, nil
+	case item_model.CompressionGzip:
+		return frame.NewGzipCodec(), nil
+	case item_model.CompressionZstd:
+		return frame.NewZstdCodec()
+	default:
+		return nil, errors.Errorf("unknown compression: %q", compression)
+	}
+}
+
+// encryptionCodec returns codec encrypting frames with item data key.
+// Chunk position is used as nonce prefix, so frames of different chunks never share a nonce.
+// Returns nil codec if chunks are stored unencrypted.
+func encryptionCodec(encryption item_model.Encryption, dataKey []byte, position uint8) (frame.Codec, error) {
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+
+	switch encryption {
+	case item_model.EncryptionNone, "":
+		return nil, nil
+	case item_model.EncryptionAES256GCM:
+		var block cipher.Block
+
+		block, err = aes.NewCipher(dataKey)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	case item_model.EncryptionChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(dataKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unknown encryption: %q", encryption)
+	}
+
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, uint32(position))
+
+	return frame.NewAEADCodec(aead, prefix)
+}
+
+// decodingOpener wraps chunk file opener, so opened file is read as decoded seekable stream.
+// Size of decoded stream is checked against expected chunk size.
+func (s *Usecase) decodingOpener(open func() (io.ReadSeekCloser, error), codec frame.Codec, size int64) func() (io.ReadSeekCloser, error) {
+	return func() (io.ReadSeekCloser, error) {
+		f, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		res := frame.NewReader(f, codec)
+
+		decodedSize, err := res.Size()
+		if err == nil && decodedSize != size {
+			err = frame.ErrInvalidStream
+		}
+
+		if err != nil {
+			if err := res.Close(); err != nil {
+				s.l.Error(err)
+			}
+			return nil, err
+		}
+
+		return res, nil
+	}
+}
@@ -0,0 +1,65 @@
+package item_usecase
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultContentType is used for content of unknown type.
+const defaultContentType = "application/octet-stream"
+
+// sniffLen is a number of leading bytes content type is detected by.
+const sniffLen = 512
+
+// contentType returns media type of an item. Declared type is used if it's valid and specific,
+// otherwise type is detected from leading bytes of the content, refined by the name extension.
+func (s *Usecase) contentType(f Opener, name, declared string) string {
+	if declared != "" {
+		mediaType, params, err := mime.ParseMediaType(declared)
+		if err == nil && mediaType != defaultContentType {
+			return mime.FormatMediaType(mediaType, params)
+		}
+	}
+
+	res := defaultContentType
+
+	if sniffed, err := sniffContentType(f); err != nil {
+		s.l.Error(err)
+	} else {
+		res = sniffed
+	}
+
+	// Sniffing tells generic types only for text and unknown binary content, extension is more specific then.
+	if res == defaultContentType || strings.HasPrefix(res, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+			res = byExt
+		}
+	}
+
+	return res
+}
+
+// sniffContentType detects content type from leading bytes of the content.
+func sniffContentType(f Opener) (res string, err error) {
+	file, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	buf := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -0,0 +1,328 @@
+package item_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/pkg/errors"
+	"time"
+)
+
+var (
+	ErrItemNotFound     = errors.New("item not found")
+	ErrItemBusy         = errors.New("item is being stored")
+	ErrItemNotTrashed   = errors.New("item isn't deleted")
+	ErrContainerTrashed = errors.New("container of the item is deleted, restore it first")
+)
+
+const (
+	// deletionBatch is a number of queued chunk deletions taken at once.
+	deletionBatch = 100
+	// deletionInterval is an interval queued chunk deletions are checked with, besides deleting new items.
+	deletionInterval = time.Minute
+)
+
+// Remove deletes an item softly: it's moved to trash, unless permanent is set. Item in trash is deleted permanently only.
+// Versioned item is kept and hidden behind a new delete marker instead, or all its versions are deleted permanently.
+// Specified version is deleted permanently, delete markers included: deleting a delete marker makes the previous
+// version visible again.
+func (s *Usecase) Remove(ctx context.Context, id, version string, permanent bool) error {
+	itm, err := s.itemService.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+
+	if itm.Trashed != nil {
+		if !permanent || version != "" {
+			return ErrItemNotFound
+		}
+		return s.Delete(ctx, itm.ID)
+	}
+
+	versioned, err := s.isVersioned(ctx, itm)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version != "":
+		if itm, err = s.version(ctx, itm, versioned, version); err != nil {
+			return err
+		}
+		return s.Delete(ctx, itm.ID)
+	case permanent && versioned:
+		return s.deleteVersions(ctx, itm)
+	case permanent:
+		return s.Delete(ctx, itm.ID)
+	case versioned:
+		return s.markDeleted(ctx, itm)
+	default:
+		return s.trash(ctx, itm)
+	}
+}
+
+// Restore takes item out of trash. Versioned item hidden behind a delete marker is restored by deleting the marker.
+// Item can't be restored out of container in trash.
+func (s *Usecase) Restore(ctx context.Context, id string) (item_model.Item, error) {
+	itm, err := s.itemService.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return item_model.Item{}, ErrItemNotFound
+		}
+		return item_model.Item{}, err
+	}
+
+	cont, err := s.containerService.Get(ctx, itm.ContainerID)
+	if err != nil && !errors.Is(err, sqlite.ErrNotFound) {
+		return item_model.Item{}, err
+	}
+
+	if cont.Trashed != nil {
+		return item_model.Item{}, ErrContainerTrashed
+	}
+
+	if itm.Trashed == nil && cont.Versioning {
+		return s.restoreVersion(ctx, itm)
+	}
+
+	if itm.Trashed == nil {
+		return item_model.Item{}, ErrItemNotTrashed
+	}
+
+	err = s.itemService.Restore(ctx, itm.ID)
+	switch {
+	// Restored concurrently.
+	case errors.Is(err, sqlite.ErrNotFound):
+		return item_model.Item{}, ErrItemNotTrashed
+	case err != nil:
+		return item_model.Item{}, err
+	}
+
+	itm.Trashed = nil
+
+	return itm, nil
+}
+
+// PurgeTrash deletes items moved to trash before the time permanently. Returns number of deleted items.
+func (s *Usecase) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	items, err := s.itemService.ListTrashed(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int
+
+	for _, itm := range items {
+		// Items restored meanwhile aren't found.
+		err = s.DeleteTrashed(ctx, itm.ID, before)
+		if errors.Is(err, ErrItemNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return purged, errors.Wrapf(err, "item %s", itm.ID)
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// DeleteTrashed deletes item permanently, only if it's moved to trash before the time.
+// Returns ErrItemNotFound if there is no such item, e.g. it's restored.
+func (s *Usecase) DeleteTrashed(ctx context.Context, id string, before time.Time) error {
+	if err := s.itemService.DeleteTrashed(ctx, id, before); err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+
+	s.wakeDeletions()
+
+	return nil
+}
+
+// trash moves item to trash. Items being stored by this instance can't be deleted until storing is finished.
+func (s *Usecase) trash(ctx context.Context, itm item_model.Item) error {
+	if progress, ok := s.progress.get(itm.ID); ok && !progress.Done() {
+		return ErrItemBusy
+	}
+
+	if err := s.itemService.Trash(ctx, itm.ID); err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// markDeleted hides versioned item behind a new delete marker.
+func (s *Usecase) markDeleted(ctx context.Context, itm item_model.Item) error {
+	latest, err := s.Resolve(ctx, itm.ID, "")
+	if err != nil {
+		return err
+	}
+
+	// Pending version is going to be the latest one.
+	if latest.Status != item_model.ItemStatusOK {
+		return ErrItemBusy
+	}
+
+	_, err = s.itemService.CreateDeleteMarker(ctx, latest)
+
+	return err
+}
+
+// restoreVersion deletes delete marker hiding the item, if the latest stored version is one.
+func (s *Usecase) restoreVersion(ctx context.Context, itm item_model.Item) (item_model.Item, error) {
+	versions, err := s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	for _, v := range versions {
+		if v.Status != item_model.ItemStatusOK {
+			continue
+		}
+
+		if !v.DeleteMarker {
+			return item_model.Item{}, ErrItemNotTrashed
+		}
+
+		if err = s.Delete(ctx, v.ID); err != nil {
+			return item_model.Item{}, err
+		}
+
+		return s.Resolve(ctx, itm.ID, "")
+	}
+
+	return item_model.Item{}, ErrItemNotTrashed
+}
+
+// deleteVersions deletes all versions of the item permanently.
+func (s *Usecase) deleteVersions(ctx context.Context, itm item_model.Item) error {
+	versions, err := s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		err = s.Delete(ctx, v.ID)
+		if err != nil && !errors.Is(err, ErrItemNotFound) {
+			return errors.Wrapf(err, "version %d", v.Version)
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes item permanently. Item and its chunk models are deleted at once, while chunk files are removed
+// from file servers in background, failed removals are retried. Items being stored by this instance can't be deleted
+// until storing is finished.
+func (s *Usecase) Delete(ctx context.Context, id string) error {
+	if progress, ok := s.progress.get(id); ok && !progress.Done() {
+		return ErrItemBusy
+	}
+
+	if err := s.itemService.Delete(ctx, id); err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+
+	s.wakeDeletions()
+
+	return nil
+}
+
+// RunDeletions removes queued chunk files from file servers until ctx is done.
+func (s *Usecase) RunDeletions(ctx context.Context) {
+	ticker := time.NewTicker(deletionInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processDeletions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.deletionWake:
+		}
+	}
+}
+
+// wakeDeletions makes deletion worker check the queue without waiting for the next interval.
+func (s *Usecase) wakeDeletions() {
+	select {
+	case s.deletionWake <- struct{}{}:
+	default:
+	}
+}
+
+// discardChunks queues chunks of an item to be removed, e.g. when storing of the item failed.
+func (s *Usecase) discardChunks(ctx context.Context, itemID string) {
+	chunks, err := s.chunkService.GetItemChunks(ctx, itemID)
+	if err != nil {
+		s.l.WithError(err).Errorf("Chunks of item %s are left on file servers.", itemID)
+		return
+	}
+
+	for _, chnk := range chunks {
+		if err := s.chunkService.Delete(ctx, chnk); err != nil {
+			s.l.WithError(err).Errorf("Chunk file %s is left on file server %s.", chnk.FilePath, chnk.FileServerID)
+		}
+	}
+
+	s.wakeDeletions()
+}
+
+// processDeletions attempts due deletions. Stops on storage errors, so failing deletions aren't attempted in a loop.
+func (s *Usecase) processDeletions(ctx context.Context) {
+	for {
+		deletions, err := s.chunkService.DueDeletions(ctx, deletionBatch)
+		if err != nil {
+			s.l.Error(err)
+			return
+		}
+
+		for _, deletion := range deletions {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err = s.processDeletion(ctx, deletion); err != nil {
+				s.l.Error(err)
+				return
+			}
+		}
+
+		if len(deletions) < deletionBatch {
+			return
+		}
+	}
+}
+
+// processDeletion removes chunk file, releasing used space of its file server, or schedules the next attempt.
+func (s *Usecase) processDeletion(ctx context.Context, deletion chunk_model.Deletion) error {
+	err := s.fileServerService.RemoveChunkFile(ctx, deletion.FileServerID, deletion.FilePath)
+	if err != nil {
+		s.l.WithError(err).Warnf(
+			"Removing chunk file %s from file server %s failed, attempt %d.",
+			deletion.FilePath, deletion.FileServerID, deletion.Attempts+1,
+		)
+
+		return s.chunkService.RetryDeletion(ctx, deletion, err)
+	}
+
+	return s.chunkService.CompleteDeletion(ctx, deletion)
+}
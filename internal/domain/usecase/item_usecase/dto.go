@@ -1,13 +1,97 @@
 package item_usecase
 
 import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
+	"io"
 	"mime/multipart"
+	"time"
 )
 
+// Opener opens source of an item. Uploaded multipart files and imported files implement it.
+type Opener interface {
+	Open() (multipart.File, error)
+}
+
 type StoreItemDTO struct {
-	F           *multipart.FileHeader
+	F           Opener
 	Name        string
 	ContainerID string
 	Size        int64
+	// ContentType is a declared media type, detected from the content if empty or unspecific.
+	ContentType string
+	Compression item_model.Compression
+	Encryption  item_model.Encryption
+	Metadata    item_model.Metadata
 	Close       func()
 }
+
+// ListItemsDTO specifies a page of container items, optionally filtered by status and metadata.
+type ListItemsDTO struct {
+	ContainerID string
+	Filter      item_model.ListFilter
+	Params      page_model.Params
+}
+
+// ItemPage is a page of items. Next is a cursor of the next page, empty on the last one.
+// Total is a number of items matching the filters on all pages.
+type ItemPage struct {
+	Items []item_model.Item `json:"items"`
+	Total int               `json:"total"`
+	Next  string            `json:"next,omitempty"`
+}
+
+// UpdateItemDTO specifies item fields to be changed, nil fields are kept.
+// Metadata entries are merged into item metadata, null values remove entries.
+type UpdateItemDTO struct {
+	Name        *string            `json:"name,omitempty"`
+	ContainerID *string            `json:"container_id,omitempty"`
+	Metadata    map[string]*string `json:"metadata,omitempty"`
+}
+
+// CopyItemDTO specifies item to be copied. Empty name and container ID are taken from the item.
+type CopyItemDTO struct {
+	ItemID      string `json:"item_id"`
+	ContainerID string `json:"container_id,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// ImportItemDTO specifies source to import item from: either URL, or path on the local server,
+// or path on a file server if FileServerID is set, or Body.
+type ImportItemDTO struct {
+	URL          string                 `json:"url,omitempty"`
+	Path         string                 `json:"path,omitempty"`
+	FileServerID string                 `json:"file_server_id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	ContentType  string                 `json:"content_type,omitempty"`
+	ContainerID  string                 `json:"container_id"`
+	Compression  item_model.Compression `json:"compression,omitempty"`
+	Encryption   item_model.Encryption  `json:"encryption,omitempty"`
+	Metadata     item_model.Metadata    `json:"metadata,omitempty"`
+	// Body is a source read by the gateway itself, e.g. request body, instead of URL or path. Name is required then.
+	Body io.Reader `json:"-"`
+}
+
+// ContentReader reads item content sequentially with Read and Seek, and at random offsets with ReadAt.
+// ReadAt doesn't affect offset of Read and is safe for concurrent use.
+type ContentReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// Content describes downloadable content of an item.
+type Content struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+	// ContentType is a media type of the content.
+	ContentType string
+	// Hash is hex encoded SHA-256 of the content, empty until item is stored.
+	Hash string
+	// CacheControl is a caching policy of the item container.
+	CacheControl string
+	// RateLimit is a download rate limit of the item container, bytes per second.
+	RateLimit int64
+	// Metadata is user defined metadata of the item.
+	Metadata item_model.Metadata
+}
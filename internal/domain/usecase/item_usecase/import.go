@@ -0,0 +1,129 @@
+package item_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/file_server_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/import_service"
+	"github.com/pkg/errors"
+	"path"
+)
+
+// Errors of import sources, the same as ones of import service.
+var (
+	ErrInvalidSource  = import_service.ErrInvalidSource
+	ErrSourceNotFound = import_service.ErrSourceNotFound
+)
+
+// Import pulls item from the source to the local server and stores it the same way as an uploaded one.
+// Local files are read in place, other sources are copied to a temporary file first.
+func (s *Usecase) Import(ctx context.Context, dto ImportItemDTO) (item_model.Item, error) {
+	// Checked before pulling the source, which can take long.
+	if err := dto.Metadata.Validate(); err != nil {
+		return item_model.Item{}, err
+	}
+
+	if err := validateImportName(dto); err != nil {
+		return item_model.Item{}, err
+	}
+
+	src, err := s.pullSource(ctx, dto)
+	if err != nil {
+		return item_model.Item{}, sourceError(err)
+	}
+
+	removeSource := func() {
+		if err := src.Remove(); err != nil {
+			s.l.Error(err)
+		}
+	}
+
+	name := dto.Name
+	if name == "" {
+		name = src.Name
+	}
+
+	contentType := dto.ContentType
+	if contentType == "" {
+		contentType = src.ContentType
+	}
+
+	storeParams := StoreItemDTO{
+		F:           src,
+		Name:        name,
+		ContentType: contentType,
+		ContainerID: dto.ContainerID,
+		Size:        src.Size,
+		Compression: dto.Compression,
+		Encryption:  dto.Encryption,
+		Metadata:    dto.Metadata,
+		Close:       removeSource,
+	}
+
+	res, err := s.Store(ctx, storeParams)
+	if err != nil {
+		removeSource()
+		return item_model.Item{}, err
+	}
+
+	return res, nil
+}
+
+// pullSource makes item source available on the local server.
+func (s *Usecase) pullSource(ctx context.Context, dto ImportItemDTO) (import_service.Source, error) {
+	switch {
+	case dto.Body != nil:
+		if dto.Name == "" {
+			return import_service.Source{}, errors.Wrap(ErrInvalidSource, "name of item must be specified")
+		}
+
+		return s.importService.FromReader(dto.Name, dto.Body)
+	case dto.URL != "" && dto.Path == "" && dto.FileServerID == "":
+		return s.importService.FromURL(ctx, dto.URL)
+	case dto.URL == "" && dto.Path != "" && dto.FileServerID == "":
+		return s.importService.FromLocal(dto.Path)
+	case dto.URL == "" && dto.Path != "" && dto.FileServerID != "":
+		f, err := s.fileServerService.OpenFile(ctx, dto.FileServerID, dto.Path)
+		if err != nil {
+			return import_service.Source{}, err
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				s.l.Error(err)
+			}
+		}()
+
+		return s.importService.FromReader(path.Base(dto.Path), f)
+	default:
+		return import_service.Source{}, errors.Wrap(ErrInvalidSource, "either url, or path, or file server id with path must be specified")
+	}
+}
+
+// validateImportName checks name of item to import, if it's known before pulling the source.
+// Name of URL source is checked on store, once it's known.
+func validateImportName(dto ImportItemDTO) error {
+	switch {
+	case dto.Name != "":
+		return validateName(dto.Name)
+	case dto.Path != "":
+		return validateName(path.Base(dto.Path))
+	default:
+		return nil
+	}
+}
+
+// sourceError turns other errors of services caused by import source into ErrInvalidSource or ErrSourceNotFound.
+func sourceError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidSource), errors.Is(err, ErrSourceNotFound):
+		return err
+	case errors.Is(err, file_server_service.ErrFileNotFound), errors.Is(err, sqlite.ErrNotFound):
+		return errors.Wrap(ErrSourceNotFound, err.Error())
+	case errors.Is(err, import_service.ErrLocalImportDisabled), errors.Is(err, import_service.ErrAddressForbidden),
+		errors.Is(err, file_server_service.ErrImportDisabled), errors.Is(err, file_server_service.ErrOutOfImportPath):
+		return errors.Wrap(ErrInvalidSource, err.Error())
+	default:
+		return err
+	}
+}
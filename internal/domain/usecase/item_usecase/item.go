@@ -2,27 +2,54 @@ package item_usecase
 
 import (
 	"context"
-	"github.com/PavelKhripkov/object_storage/internal/domain/model/file_server_model"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/chunk_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/chunk_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/container_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/file_server_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/import_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
 	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_split_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/key_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/transfer_service"
 	"github.com/PavelKhripkov/object_storage/pkg/content_mapper"
+	"github.com/PavelKhripkov/object_storage/pkg/disk_cache"
+	"github.com/PavelKhripkov/object_storage/pkg/throttle"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"io"
-	"mime/multipart"
+	"strconv"
+	"time"
 )
 
 const defaultPartsCount = 6 // TODO better get from config
 
+var (
+	ErrStoreFailed = errors.New("item couldn't be stored")
+	ErrWaitTimeout = errors.New("item isn't stored in time")
+)
+
 // Usecase represents item use cases.
 type Usecase struct {
 	itemService       *item_service.Service
 	chunkService      *chunk_service.Service
+	containerService  *container_service.Service
 	fileServerService *file_server_service.Service
 	fileSplitService  *item_split_service.FileSplitService
+	keyService        *key_service.Service
+	transferService   *transfer_service.Service
+	importService     *import_service.Service
+
+	readAhead content_mapper.ReadAhead
+	retry     content_mapper.Retry
+	egress    *throttle.Bucket
+
+	progress *progressTracker
+	spools   *spoolRegistry
+
+	deletionWake chan struct{}
 
 	l *log.Entry
 }
@@ -31,14 +58,31 @@ type Usecase struct {
 func NewItemUsecase(
 	itemService *item_service.Service,
 	chunkService *chunk_service.Service,
+	containerService *container_service.Service,
 	fileService *file_server_service.Service,
 	fileSplitService *item_split_service.FileSplitService,
+	keyService *key_service.Service,
+	transferService *transfer_service.Service,
+	importService *import_service.Service,
+	readAhead content_mapper.ReadAhead,
+	retry content_mapper.Retry,
+	egress *throttle.Bucket,
 	l *log.Logger) *Usecase {
 	return &Usecase{
 		itemService:       itemService,
 		chunkService:      chunkService,
+		containerService:  containerService,
 		fileServerService: fileService,
 		fileSplitService:  fileSplitService,
+		keyService:        keyService,
+		transferService:   transferService,
+		importService:     importService,
+		readAhead:         readAhead,
+		retry:             retry,
+		egress:            egress,
+		progress:          newProgressTracker(),
+		spools:            newSpoolRegistry(),
+		deletionWake:      make(chan struct{}, 1),
 		l:                 l.WithField("component", "itemUsecase"),
 	}
 }
@@ -53,209 +97,410 @@ func (s *Usecase) Get(ctx context.Context, id string) (item_model.Item, error) {
 	return res, nil
 }
 
-type chunkJob struct {
-	Position      uint8
-	Start, End    int64
-	Processed     bool
-	FileServiceID string
-	FilePath      string
+// List returns items of the container.
+func (s *Usecase) List(ctx context.Context, containerID string) ([]item_model.Item, error) {
+	res, err := s.itemService.List(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-// Store creates item model and starts storing item chunks on file servers.
-func (s *Usecase) Store(ctx context.Context, dto StoreItemDTO) (item_model.Item, error) {
-	params := item_service.CreateItemDTO{
-		Name:        dto.Name,
-		ContainerID: dto.ContainerID,
-		Size:        dto.Size,
+// ListPage returns a page of container items.
+func (s *Usecase) ListPage(ctx context.Context, dto ListItemsDTO) (ItemPage, error) {
+	switch dto.Filter.Status {
+	case "", item_model.ItemStatusOK, item_model.ItemStatusFail, item_model.ItemStatusPending:
+	default:
+		return ItemPage{}, errors.Wrapf(page_model.ErrInvalidParams, "unknown status %q", dto.Filter.Status)
 	}
 
-	newItem, err := s.itemService.Create(ctx, params)
+	for k := range dto.Filter.Metadata {
+		if err := item_model.ValidateMetadataKey(k); err != nil {
+			return ItemPage{}, errors.Wrap(page_model.ErrInvalidParams, err.Error())
+		}
+	}
+
+	q, err := dto.Params.Query(page_model.SortName, page_model.SortSize, page_model.SortCreated)
 	if err != nil {
-		return item_model.Item{}, err
+		return ItemPage{}, err
 	}
 
-	go s.store(context.TODO(), newItem, dto)
+	items, total, err := s.itemService.ListPage(ctx, dto.ContainerID, dto.Filter, q)
+	if err != nil {
+		return ItemPage{}, err
+	}
 
-	return newItem, nil
+	res := ItemPage{Items: items, Total: total}
+
+	if len(items) > q.Limit {
+		res.Items = items[:q.Limit]
+		last := res.Items[q.Limit-1]
+
+		var key string
+
+		switch q.Sort {
+		case page_model.SortSize:
+			key = strconv.FormatInt(last.Size, 10)
+		case page_model.SortCreated:
+			key = strconv.FormatInt(last.Created.UnixMilli(), 10)
+		default:
+			key = last.Name
+		}
+
+		res.Next = q.Next(key, last.ID).Encode()
+	}
+
+	return res, nil
 }
 
-// store runs in background and performs:
-// 1. item splitting into chunks;
-// 2. getting available file servers;
-// 3. storing item chunks on file servers.
-func (s *Usecase) store(ctx context.Context, itm item_model.Item, dto StoreItemDTO) {
-	s.l.Infof("Storing file %s, of size %d bytes.", dto.Name, dto.Size)
+// Store creates item model and starts storing item chunks on file servers. Item stored into versioned container
+// is the next version of items with the same name.
+// Compression and encryption are taken from the container, if they're not specified explicitly.
+// Encrypted items get their own data key, wrapped with the active master key.
+// Content type is detected from the content, unless a specific one is declared.
+func (s *Usecase) Store(ctx context.Context, dto StoreItemDTO) (item_model.Item, error) {
+	cont, err := s.containerService.Get(ctx, dto.ContainerID)
+	if err != nil && !errors.Is(err, sqlite.ErrNotFound) {
+		return item_model.Item{}, err
+	}
 
-	if dto.Close != nil {
-		defer dto.Close()
+	if cont.Trashed != nil {
+		return item_model.Item{}, ErrContainerNotFound
 	}
 
-	partsCount := defaultPartsCount
+	if dto.Compression == "" {
+		dto.Compression = cont.Compression
+	}
 
-	fileServerCount, err := s.fileServerService.Count(ctx)
-	if err != nil {
-		// TODO handle error
-		return
+	if dto.Compression == "" {
+		dto.Compression = item_model.CompressionNone
 	}
 
-	if fileServerCount < 1 {
-		s.l.Error("No available file servers found.")
-		_, err := s.itemService.Update(ctx, itm, item_service.UpdateItemDTO{Status: item_model.ItemStatusFail.Pointer()})
-		if err != nil {
-			s.l.Error(err)
-		}
-		return
+	if dto.Encryption == "" {
+		dto.Encryption = cont.Encryption
 	}
 
-	// If there are too few available file servers, we're reducing target chunk amount.
-	if partsCount > fileServerCount {
-		partsCount = fileServerCount
+	if dto.Encryption == "" {
+		dto.Encryption = s.defaultEncryption()
 	}
 
-	// Split item into chunks.
-	chunkPositions, err := s.fileSplitService.SplitFileBySize(dto.Size, partsCount)
-	if err != nil {
-		s.l.Error(err)
-		_, err = s.itemService.Update(ctx, itm, item_service.UpdateItemDTO{Status: item_model.ItemStatusFail.Pointer()})
+	if err := dto.Compression.Validate(); err != nil {
+		return item_model.Item{}, err
+	}
+
+	if err := dto.Encryption.Validate(); err != nil {
+		return item_model.Item{}, err
+	}
+
+	if err := dto.Metadata.Validate(); err != nil {
+		return item_model.Item{}, err
+	}
+
+	if err := validateName(dto.Name); err != nil {
+		return item_model.Item{}, err
+	}
+
+	params := item_service.CreateItemDTO{
+		Name:        dto.Name,
+		ContainerID: dto.ContainerID,
+		Size:        dto.Size,
+		ContentType: s.contentType(dto.F, dto.Name, dto.ContentType),
+		Compression: dto.Compression,
+		Encryption:  dto.Encryption,
+		Metadata:    dto.Metadata,
+		Versioned:   cont.Versioning,
+	}
+
+	var dataKey []byte
+
+	if dto.Encryption != item_model.EncryptionNone {
+		dataKey, params.DataKey, params.KeyID, err = s.keyService.GenerateDataKey()
 		if err != nil {
-			s.l.Error(err)
+			return item_model.Item{}, err
 		}
-		return
 	}
 
-	chunkJobs := make([]chunkJob, partsCount)
+	newItem, err := s.itemService.Create(ctx, params)
+	if err != nil {
+		return item_model.Item{}, err
+	}
 
-	// Create chunk jobs.
-	for i, c := range chunkPositions {
-		newChunkJob := chunkJob{
-			Position: uint8(i),
-			Start:    c,
-		}
+	s.progress.start(newItem)
+	s.spools.add(newItem.ID, dto.F, dto.Close)
 
-		if i != len(chunkJobs)-1 {
-			newChunkJob.End = chunkPositions[i+1] - 1
-		} else {
-			newChunkJob.End = dto.Size - 1
-		}
+	go s.store(context.TODO(), newItem, dto, dataKey)
 
-		chunkJobs[i] = newChunkJob
+	return newItem, nil
+}
+
+// Progress returns transfer progress of an item.
+// Items not being stored by this instance are reported by their status only.
+func (s *Usecase) Progress(ctx context.Context, id string) (Progress, error) {
+	if res, ok := s.progress.get(id); ok {
+		return res, nil
 	}
 
-	jobChannel := make(chan chunkJob, len(chunkJobs))
-	defer close(jobChannel)
+	itm, err := s.itemService.Get(ctx, id)
+	if err != nil {
+		return Progress{}, err
+	}
 
-	// Since jobs are small, we can store all of them in a buffered channel.
-	for _, c := range chunkJobs {
-		jobChannel <- c
+	res := Progress{
+		ItemID: itm.ID,
+		Status: itm.Status,
+		Size:   itm.Size,
 	}
 
-	success := 0
-	usedServices := make(map[string]bool)
+	if itm.Status == item_model.ItemStatusOK {
+		res.BytesSent = itm.Size
+	}
 
-	// Reading from job channel until either all chunks are stored successfully or unrecoverable error encountered.
-	for success < len(chunkJobs) {
-		select {
-		case c := <-jobChannel:
-			// New job or the one that couldn't be stored on a file server.
-			if !c.Processed {
-				delete(usedServices, c.FileServiceID)
+	return res, nil
+}
 
-				fileServer, err := s.fileServerService.ChooseOneExcluding(ctx, usedServices)
-				if err != nil {
-					s.l.WithError(err).Error("File server selection error.")
-				}
+// WatchProgress returns a channel receiving progress of an item on every state transition
+// and periodically while bytes are being transferred. Channel is closed after the item reaches its final status
+// or context is done.
+func (s *Usecase) WatchProgress(ctx context.Context, id string) (<-chan Progress, error) {
+	current, err := s.Progress(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-				if fileServer.GetFreeSpace() < c.End-c.Start+1 {
-					s.l.Error("no free space on file servers")
+	res := make(chan Progress)
+	changed, stop := s.progress.watch(id)
 
-					_, err = s.itemService.Update(ctx, itm, item_service.UpdateItemDTO{Status: item_model.ItemStatusFail.Pointer()})
-					if err != nil {
-						s.l.Error(err)
-					}
-					return
+	go func() {
+		defer close(res)
+		defer stop()
 
-					// TODO clean up created chunks
-				}
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
 
-				usedServices[fileServer.GetID()] = true
-				go s.storeWorker(ctx, dto.F, c, fileServer, jobChannel)
-
-				// The one successfully stored.
-			} else {
-				createParams := chunk_service.CreateChunkDTO{
-					ItemID:       itm.ID,
-					FileServerID: c.FileServiceID,
-					FilePath:     c.FilePath,
-					Position:     c.Position,
-					Size:         c.End - c.Start + 1,
-				}
+		for {
+			select {
+			case res <- current:
+			case <-ctx.Done():
+				return
+			}
 
-				_, err = s.chunkService.Create(ctx, createParams)
-				if err != nil {
-					// TODO handle error
+			if current.Done() {
+				return
+			}
+
+			last := current
+
+			for current.version == last.version && current.Status == last.Status && current.BytesSent == last.BytesSent {
+				select {
+				case <-changed:
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
 				}
 
-				err = s.fileServerService.UpdateUsedSpace(ctx, c.FileServiceID, createParams.Size)
+				current, err = s.Progress(ctx, id)
 				if err != nil {
+					s.l.Error(err)
 					return
 				}
-
-				success++
 			}
-		case <-ctx.Done():
-			s.l.Warn(ctx.Err())
-			// TODO clean up created chunkJobs
 		}
+	}()
 
+	return res, nil
+}
+
+// WaitStored blocks until all chunks of an item are stored and returns the item.
+// Returns ErrStoreFailed if storing failed and ErrWaitTimeout if item is still pending after timeout.
+func (s *Usecase) WaitStored(ctx context.Context, id string, timeout time.Duration) (item_model.Item, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	progress, err := s.WatchProgress(waitCtx, id)
+	if err != nil {
+		return item_model.Item{}, err
 	}
-	chunkPosCount := uint8(len(chunkPositions))
 
-	// Now can store chunk info into item model.
-	changeItemParams := item_service.UpdateItemDTO{
-		Status:     item_model.ItemStatusOK.Pointer(),
-		ChunkCount: &chunkPosCount,
+	var last Progress
+	for last = range progress {
 	}
 
-	_, err = s.itemService.Update(ctx, itm, changeItemParams)
+	itm, err := s.itemService.Get(ctx, id)
 	if err != nil {
-		s.l.Error(err)
+		return item_model.Item{}, err
 	}
+
+	// Final status is taken from progress, since it's known before item is updated.
+	switch last.Status {
+	case item_model.ItemStatusOK:
+		itm.Status = last.Status
+		return itm, nil
+	case item_model.ItemStatusFail:
+		itm.Status = last.Status
+		return itm, ErrStoreFailed
+	default:
+		return itm, ErrWaitTimeout
+	}
+}
+
+// CacheStats returns usage of chunk cache. Reports false if cache is disabled.
+func (s *Usecase) CacheStats() (disk_cache.Stats, bool) {
+	return s.fileServerService.CacheStats()
 }
 
-// storeWorker stores chunk on file server and replies into job queue with results.
-func (s *Usecase) storeWorker(ctx context.Context, f *multipart.FileHeader, c chunkJob, fileService file_server_model.FileServer, queue chan<- chunkJob) {
-	if FilePath, err := s.fileServerService.StoreChunk(ctx, fileService, f, c.Start, c.End-c.Start+1); err != nil {
-		s.l.Error(err)
-		return
-	} else {
+// TransferStats returns state of chunk transfers of all items.
+func (s *Usecase) TransferStats() transfer_service.Stats {
+	return s.transferService.Stats()
+}
 
-		c.FilePath = FilePath
-		c.Processed = true
+// Stat describes content of an item as Download does, but doesn't prepare chunks.
+func (s *Usecase) Stat(ctx context.Context, id string) (Content, error) {
+	itm, err := s.getItem(ctx, id)
+	if err != nil {
+		return Content{}, err
 	}
 
-	c.FileServiceID = fileService.GetID()
-	queue <- c
+	if itm.Status != item_model.ItemStatusOK && (itm.Status != item_model.ItemStatusPending || !s.spools.has(id)) {
+		return Content{}, downloadError(itm)
+	}
+
+	return s.content(ctx, itm)
 }
 
-// Download prepares chunks, opens streams and returns io.ReadSeeker that can be used to read item seamlessly.
-func (s *Usecase) Download(ctx context.Context, id string) (io.ReadSeeker, string, error) {
-	itm, err := s.itemService.Get(ctx, id)
+// Download opens item content to be sent to a client.
+// Reading is limited by the lowest of rate and container rate limit, if any, and by egress limit.
+func (s *Usecase) Download(ctx context.Context, id string, rate int64) (io.ReadSeekCloser, Content, error) {
+	reader, content, err := s.Open(ctx, id)
 	if err != nil {
-		return nil, "", err
+		return nil, Content{}, err
 	}
 
-	// TODO item can be taken from local server until it's not transferred to remote ones.
-	if itm.Status != item_model.ItemStatusOK {
-		return nil, "", errors.Errorf("item can't be downloaded, current status: %s", itm.Status)
+	return throttle.NewReadSeekCloser(ctx, reader, throttle.NewBucket(lowestRate(rate, content.RateLimit)), s.egress), content, nil
+}
+
+// Open prepares chunks and returns reader of item content, which can be read seamlessly or at random offsets,
+// e.g. by several goroutines. Chunks are opened on demand. Reader must be closed after usage.
+// Pending items are read from the upload kept on this server, mixed with chunks already stored on file servers.
+func (s *Usecase) Open(ctx context.Context, id string) (ContentReader, Content, error) {
+	// Upload is referenced before getting the item, so it can't be removed after the item is found pending.
+	f, planned, release, spooled := s.spools.acquire(id)
+
+	itm, err := s.getItem(ctx, id)
+	if err != nil {
+		if spooled {
+			release()
+		}
+		return nil, Content{}, err
+	}
+
+	// Upload is read for pending items only.
+	if spooled && itm.Status != item_model.ItemStatusPending {
+		release()
+		spooled = false
+	}
+
+	if itm.Status != item_model.ItemStatusOK && !spooled {
+		return nil, Content{}, downloadError(itm)
+	}
+
+	content, err := s.content(ctx, itm)
+	if err != nil {
+		if spooled {
+			release()
+		}
+		return nil, Content{}, err
 	}
 
 	chunks, err := s.chunkService.GetItemChunks(ctx, id)
+
+	var parts []*content_mapper.Part
+
+	if err == nil {
+		if spooled {
+			parts, err = s.pendingParts(ctx, itm, chunks, f, planned)
+		} else {
+			parts, err = s.storedParts(ctx, itm, chunks)
+		}
+	}
+
+	var contentMapper *content_mapper.ContentMapper
+
+	if err == nil {
+		contentMapper, err = content_mapper.NewContentMapper(
+			s.l.Logger.WithField("component", "ContentMapper"),
+			parts,
+			itm.Size,
+			content_mapper.WithReadAhead(s.readAhead),
+			content_mapper.WithRetry(s.retry),
+			content_mapper.WithContext(ctx),
+		)
+	}
+
 	if err != nil {
-		return nil, "", err
+		if spooled {
+			release()
+		}
+		return nil, Content{}, err
 	}
 
+	if spooled {
+		return spoolReader{ContentReader: contentMapper, release: release}, content, nil
+	}
+
+	return contentMapper, content, nil
+}
+
+// downloadError explains why item, which isn't stored, can't be downloaded.
+func downloadError(itm item_model.Item) error {
+	if itm.Status == item_model.ItemStatusPending {
+		return errors.Wrap(ErrItemNotStored, "item is being stored by another server")
+	}
+
+	return errors.Wrapf(ErrItemNotStored, "item can't be downloaded, current status: %s", itm.Status)
+}
+
+// content describes content of an item. Caching policy is taken from the item container.
+func (s *Usecase) content(ctx context.Context, itm item_model.Item) (Content, error) {
+	cont, err := s.containerService.Get(ctx, itm.ContainerID)
+	if err != nil && !errors.Is(err, sqlite.ErrNotFound) {
+		return Content{}, err
+	}
+
+	return Content{
+		Name:         itm.Name,
+		Size:         itm.Size,
+		Modified:     itm.Modified,
+		ContentType:  itm.ContentType,
+		Hash:         itm.Hash,
+		CacheControl: cont.CacheControl,
+		RateLimit:    cont.RateLimit,
+		Metadata:     itm.Metadata,
+	}, nil
+}
+
+// lowestRate returns the lowest of positive rates, zero if there are none.
+func lowestRate(rates ...int64) int64 {
+	var res int64
+
+	for _, rate := range rates {
+		if rate > 0 && (res == 0 || rate < res) {
+			res = rate
+		}
+	}
+
+	return res
+}
+
+// storedParts prepares parts of an item read from chunks stored on file servers.
+func (s *Usecase) storedParts(ctx context.Context, itm item_model.Item, chunks []chunk_model.Chunk) ([]*content_mapper.Part, error) {
 	if len(chunks) != int(itm.ChunkCount) {
-		return nil, "", errors.New("wrong chunkJob amount")
+		return nil, errors.New("wrong chunkJob amount")
+	}
+
+	dataKey, err := s.dataKey(itm)
+	if err != nil {
+		return nil, err
 	}
 
 	parts := make([]*content_mapper.Part, len(chunks))
@@ -263,23 +508,146 @@ func (s *Usecase) Download(ctx context.Context, id string) (io.ReadSeeker, strin
 
 	// Preparing chunk files for content mapper.
 	for i, chnk := range chunks {
-		chunkFile, err := s.fileServerService.OpenChunkFile(ctx, chnk)
+		chunkFile, err := s.chunkOpener(ctx, itm, dataKey, chnk)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
+
 		newPart := content_mapper.Part{
-			Start: nextStart,
-			End:   nextStart + chnk.Size - 1,
-			Open:  chunkFile,
+			Start:   nextStart,
+			End:     nextStart + chnk.Size - 1,
+			Sources: []func() (io.ReadSeekCloser, error){chunkFile},
 		}
 		nextStart += chnk.Size
 		parts[i] = &newPart
 	}
 
-	contentMapper, err := content_mapper.NewContentMapper(s.l.Logger.WithField("component", "ContentMapper"), parts, itm.Size)
+	return parts, nil
+}
+
+// pendingParts prepares parts of a pending item. Chunks already stored on file servers are read from them,
+// falling back to the upload, the rest is read from the upload. If the upload isn't split yet, it's read as a single part.
+func (s *Usecase) pendingParts(
+	ctx context.Context,
+	itm item_model.Item,
+	chunks []chunk_model.Chunk,
+	f Opener,
+	planned []chunkJob,
+) ([]*content_mapper.Part, error) {
+	if len(planned) == 0 {
+		planned = []chunkJob{{Start: 0, End: itm.Size - 1}}
+	}
+
+	stored := make(map[uint8]chunk_model.Chunk, len(chunks))
+	for _, chnk := range chunks {
+		stored[chnk.Position] = chnk
+	}
+
+	var dataKey []byte
+
+	parts := make([]*content_mapper.Part, len(planned))
+
+	for i, c := range planned {
+		newPart := content_mapper.Part{
+			Start:   c.Start,
+			End:     c.End,
+			Sources: []func() (io.ReadSeekCloser, error){spoolOpener(f, c)},
+		}
+
+		if chnk, ok := stored[c.Position]; ok && chnk.Size == c.Size() {
+			var err error
+
+			if dataKey == nil {
+				if dataKey, err = s.dataKey(itm); err != nil {
+					return nil, err
+				}
+			}
+
+			chunkFile, err := s.chunkOpener(ctx, itm, dataKey, chnk)
+			if err != nil {
+				return nil, err
+			}
+
+			newPart.Sources = append([]func() (io.ReadSeekCloser, error){chunkFile}, newPart.Sources...)
+		}
+
+		parts[i] = &newPart
+	}
+
+	return parts, nil
+}
+
+// chunkOpener returns opener of a chunk stored on file server, decoding it if needed.
+func (s *Usecase) chunkOpener(
+	ctx context.Context,
+	itm item_model.Item,
+	dataKey []byte,
+	chnk chunk_model.Chunk,
+) (func() (io.ReadSeekCloser, error), error) {
+	chunkFile, err := s.fileServerService.OpenChunkFile(ctx, chnk)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := chunkCodec(itm, dataKey, chnk.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec != nil {
+		chunkFile = s.decodingOpener(chunkFile, codec, chnk.Size)
+	}
+
+	return chunkFile, nil
+}
+
+// dataKey returns unwrapped data key of an item, nil if the item isn't encrypted.
+func (s *Usecase) dataKey(itm item_model.Item) ([]byte, error) {
+	if itm.DataKey == nil {
+		return nil, nil
+	}
+
+	return s.keyService.UnwrapDataKey(itm.KeyID, itm.DataKey)
+}
+
+// RotateKeys re-wraps data keys of all encrypted items with the active master key.
+// Chunks aren't touched, since data keys stay the same. Returns number of re-wrapped keys.
+func (s *Usecase) RotateKeys(ctx context.Context) (int, error) {
+	if !s.keyService.Enabled() {
+		return 0, key_service.ErrNoMasterKey
+	}
+
+	items, err := s.itemService.ListWrappedWithOtherKey(ctx, s.keyService.ActiveKeyID())
 	if err != nil {
-		return nil, "", err
+		return 0, err
+	}
+
+	var rotated int
+
+	for _, itm := range items {
+		wrapped, keyID, err := s.keyService.RewrapDataKey(itm.KeyID, itm.DataKey)
+		if err != nil {
+			return rotated, errors.Wrapf(err, "item %s", itm.ID)
+		}
+
+		if err = s.itemService.UpdateDataKey(ctx, itm.ID, keyID, wrapped); err != nil {
+			return rotated, err
+		}
+
+		rotated++
+	}
+
+	s.l.Infof("Data keys of %d items re-wrapped with master key %s.", rotated, s.keyService.ActiveKeyID())
+
+	return rotated, nil
+}
+
+// defaultEncryption returns encryption applied when neither upload nor container specify it.
+// Items are encrypted by default as long as master key is configured.
+func (s *Usecase) defaultEncryption() item_model.Encryption {
+	if s.keyService.Enabled() {
+		return item_model.EncryptionAES256GCM
 	}
 
-	return contentMapper, itm.Name, nil
+	return item_model.EncryptionNone
 }
@@ -0,0 +1,164 @@
+package item_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+var (
+	ErrContainerNotFound = errors.New("container not found")
+	ErrItemNotStored     = errors.New("item isn't stored")
+	ErrInvalidName       = errors.New("invalid item name")
+)
+
+// Update renames item, moves it to another container or changes its metadata. Pending items can't be updated,
+// since storing them updates the item as well. Items aren't renamed or moved from or to versioned containers,
+// since names identify versions there. Metadata of versions is changed separately.
+func (s *Usecase) Update(ctx context.Context, id string, dto UpdateItemDTO) (item_model.Item, error) {
+	itm, versioned, err := s.getVersioned(ctx, id)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	if itm.DeleteMarker {
+		return item_model.Item{}, ErrItemNotFound
+	}
+
+	if itm.Status == item_model.ItemStatusPending {
+		return item_model.Item{}, ErrItemBusy
+	}
+
+	params := item_service.UpdateItemDTO{
+		Name:        dto.Name,
+		ContainerID: dto.ContainerID,
+	}
+
+	if params.Name != nil {
+		if err = validateName(*params.Name); err != nil {
+			return item_model.Item{}, err
+		}
+
+		if versioned && *params.Name != itm.Name {
+			return item_model.Item{}, ErrItemVersioned
+		}
+	}
+
+	if params.ContainerID != nil && *params.ContainerID != itm.ContainerID {
+		cont, err := s.getContainer(ctx, *params.ContainerID)
+		if err != nil {
+			return item_model.Item{}, err
+		}
+
+		if versioned || cont.Versioning {
+			return item_model.Item{}, ErrItemVersioned
+		}
+	}
+
+	if dto.Metadata != nil {
+		metadata := make(item_model.Metadata, len(itm.Metadata)+len(dto.Metadata))
+		for k, v := range itm.Metadata {
+			metadata[k] = v
+		}
+
+		for k, v := range dto.Metadata {
+			if v == nil {
+				delete(metadata, k)
+				continue
+			}
+
+			metadata[k] = *v
+		}
+
+		if err = metadata.Validate(); err != nil {
+			return item_model.Item{}, err
+		}
+
+		params.Metadata = &metadata
+	}
+
+	return s.itemService.Update(ctx, itm, params)
+}
+
+// Copy creates a copy of stored item without transferring content: the copy refers to the same chunk files.
+// Files are removed from file servers once both items are deleted. Copy keeps name and container
+// of the source unless other ones are specified. Copy into versioned container is a new version, so copying
+// an older version in place restores it.
+func (s *Usecase) Copy(ctx context.Context, dto CopyItemDTO) (item_model.Item, error) {
+	src, err := s.getItem(ctx, dto.ItemID)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	if src.DeleteMarker {
+		return item_model.Item{}, ErrItemNotFound
+	}
+
+	if src.Status != item_model.ItemStatusOK {
+		return item_model.Item{}, ErrItemNotStored
+	}
+
+	name := src.Name
+	if dto.Name != "" {
+		if err = validateName(dto.Name); err != nil {
+			return item_model.Item{}, err
+		}
+
+		name = dto.Name
+	}
+
+	containerID := src.ContainerID
+	if dto.ContainerID != "" {
+		containerID = dto.ContainerID
+	}
+
+	cont, err := s.getContainer(ctx, containerID)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	chunks, err := s.chunkService.GetItemChunks(ctx, src.ID)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	res, err := s.itemService.Copy(ctx, src, containerID, name, chunks, cont.Versioning)
+	if err != nil {
+		// Source is deleted concurrently.
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return item_model.Item{}, ErrItemNotFound
+		}
+		return item_model.Item{}, err
+	}
+
+	return res, nil
+}
+
+// getItem returns item, ErrItemNotFound if there is no such one or it's in trash.
+func (s *Usecase) getItem(ctx context.Context, id string) (item_model.Item, error) {
+	itm, err := s.itemService.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return item_model.Item{}, ErrItemNotFound
+		}
+		return item_model.Item{}, err
+	}
+
+	if itm.Trashed != nil {
+		return item_model.Item{}, ErrItemNotFound
+	}
+
+	return itm, nil
+}
+
+// validateName checks that name can be a path element.
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return errors.Wrapf(ErrInvalidName, "%q", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package item_usecase
+
+import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressRetention is a time progress of finished item is kept for late watchers.
+const progressRetention = time.Minute
+
+// progressInterval is a period progress watchers are checking transferred bytes with.
+const progressInterval = 500 * time.Millisecond
+
+type ChunkState string
+
+const (
+	ChunkStateQueued       ChunkState = "queued"
+	ChunkStateWaiting      ChunkState = "waiting"
+	ChunkStateTransferring ChunkState = "transferring"
+	ChunkStateStored       ChunkState = "stored"
+	ChunkStateFailed       ChunkState = "failed"
+)
+
+// ChunkProgress represents transfer state of a single item chunk.
+type ChunkProgress struct {
+	Position     uint8      `json:"position"`
+	Size         int64      `json:"size"`
+	BytesSent    int64      `json:"bytes_sent"`
+	FileServerID string     `json:"file_server_id,omitempty"`
+	Retries      int        `json:"retries"`
+	State        ChunkState `json:"state"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Progress represents transfer state of an item.
+type Progress struct {
+	ItemID    string            `json:"item_id"`
+	Status    item_model.Status `json:"status"`
+	Size      int64             `json:"size"`
+	BytesSent int64             `json:"bytes_sent"`
+	Chunks    []ChunkProgress   `json:"chunks,omitempty"`
+
+	// version is increased on every change, so watchers can skip unchanged snapshots.
+	version uint64
+}
+
+// Done reports whether item reached its final status.
+func (s Progress) Done() bool {
+	return s.Status != item_model.ItemStatusPending
+}
+
+// itemProgress holds progress of an item and channels of its watchers.
+type itemProgress struct {
+	progress Progress
+	watchers map[chan struct{}]struct{}
+}
+
+// progressTracker keeps progress of items being stored by this instance.
+type progressTracker struct {
+	mu    sync.Mutex
+	items map[string]*itemProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		items: make(map[string]*itemProgress),
+	}
+}
+
+// start begins tracking of an item.
+func (s *progressTracker) start(itm item_model.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[itm.ID] = &itemProgress{
+		progress: Progress{
+			ItemID: itm.ID,
+			Status: itm.Status,
+			Size:   itm.Size,
+		},
+		watchers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// setChunks registers chunks of an item, all of them are queued.
+func (s *progressTracker) setChunks(id string, jobs []chunkJob) {
+	s.update(id, true, func(p *Progress) {
+		p.Chunks = make([]ChunkProgress, len(jobs))
+		for i, c := range jobs {
+			p.Chunks[i] = ChunkProgress{
+				Position: c.Position,
+				Size:     c.End - c.Start + 1,
+				State:    ChunkStateQueued,
+			}
+		}
+	})
+}
+
+// chunkAssigned marks chunk as waiting for transfer to a file server.
+// Transfer to another server after a failure is counted as retry.
+func (s *progressTracker) chunkAssigned(id string, position uint8, fileServerID string) {
+	s.updateChunk(id, position, true, func(c *ChunkProgress) {
+		if c.State == ChunkStateFailed {
+			c.Retries++
+		}
+
+		c.FileServerID = fileServerID
+		c.State = ChunkStateWaiting
+		c.BytesSent = 0
+		c.Error = ""
+	})
+}
+
+// chunkStarted marks chunk as being transferred.
+func (s *progressTracker) chunkStarted(id string, position uint8) {
+	s.updateChunk(id, position, true, func(c *ChunkProgress) {
+		c.State = ChunkStateTransferring
+	})
+}
+
+// chunkSent adds bytes sent to a file server.
+func (s *progressTracker) chunkSent(id string, position uint8, n int64) {
+	s.updateChunk(id, position, false, func(c *ChunkProgress) {
+		c.BytesSent += n
+	})
+}
+
+func (s *progressTracker) chunkStored(id string, position uint8) {
+	s.updateChunk(id, position, true, func(c *ChunkProgress) {
+		c.State = ChunkStateStored
+		c.BytesSent = c.Size
+	})
+}
+
+func (s *progressTracker) chunkFailed(id string, position uint8, err error) {
+	s.updateChunk(id, position, true, func(c *ChunkProgress) {
+		c.State = ChunkStateFailed
+		c.Error = err.Error()
+	})
+}
+
+// finish sets final status of an item. Progress is forgotten after retention period.
+func (s *progressTracker) finish(id string, status item_model.Status) {
+	s.update(id, true, func(p *Progress) {
+		p.Status = status
+	})
+
+	time.AfterFunc(progressRetention, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.items, id)
+	})
+}
+
+// get returns a copy of item progress.
+func (s *progressTracker) get(id string) (Progress, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return Progress{}, false
+	}
+
+	res := item.progress
+	res.Chunks = append([]ChunkProgress(nil), item.progress.Chunks...)
+	res.BytesSent = 0
+	for _, c := range res.Chunks {
+		res.BytesSent += c.BytesSent
+	}
+
+	if res.Status == item_model.ItemStatusOK {
+		res.BytesSent = res.Size
+	}
+
+	return res, true
+}
+
+// watch returns a channel signaling state transitions of an item and a function to stop watching.
+// Channel is nil if item isn't tracked.
+func (s *progressTracker) watch(id string) (<-chan struct{}, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, func() {}
+	}
+
+	ch := make(chan struct{}, 1)
+	item.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(item.watchers, ch)
+	}
+}
+
+func (s *progressTracker) updateChunk(id string, position uint8, transition bool, f func(c *ChunkProgress)) {
+	s.update(id, transition, func(p *Progress) {
+		if int(position) < len(p.Chunks) {
+			f(&p.Chunks[position])
+		}
+	})
+}
+
+// update applies changes to item progress. Watchers are notified about transitions only,
+// transferred bytes are picked up by them periodically.
+func (s *progressTracker) update(id string, transition bool, f func(p *Progress)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return
+	}
+
+	f(&item.progress)
+	item.progress.version++
+
+	if !transition {
+		return
+	}
+
+	for ch := range item.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// progressReader reports bytes read from the source to progress tracker.
+type progressReader struct {
+	src      io.Reader
+	tracker  *progressTracker
+	itemID   string
+	position uint8
+}
+
+func (s *progressReader) Read(p []byte) (int, error) {
+	n, err := s.src.Read(p)
+	if n > 0 {
+		s.tracker.chunkSent(s.itemID, s.position, int64(n))
+	}
+
+	return n, err
+}
@@ -0,0 +1,137 @@
+package item_usecase
+
+import (
+	"io"
+	"sync"
+)
+
+// spool represents an upload kept on the local server until all its chunks are stored on file servers.
+type spool struct {
+	f      Opener
+	close  func()
+	refs   int
+	chunks []chunkJob
+}
+
+// spoolRegistry keeps uploads of items being stored by this instance.
+// Upload is removed once storing is finished and no download is reading it.
+type spoolRegistry struct {
+	mu     sync.Mutex
+	spools map[string]*spool
+}
+
+func newSpoolRegistry() *spoolRegistry {
+	return &spoolRegistry{
+		spools: make(map[string]*spool),
+	}
+}
+
+// add registers upload of an item. The reference is held by storing, so it must be released once storing is finished.
+func (s *spoolRegistry) add(id string, f Opener, close func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spools[id] = &spool{
+		f:     f,
+		close: close,
+		refs:  1,
+	}
+}
+
+// setChunks registers chunks the upload is split into.
+func (s *spoolRegistry) setChunks(id string, jobs []chunkJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sp, ok := s.spools[id]; ok {
+		sp.chunks = jobs
+	}
+}
+
+// has reports whether upload of an item is kept.
+func (s *spoolRegistry) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.spools[id]
+	return ok
+}
+
+// acquire takes a reference to the upload of an item. Returns upload, its chunks, if they're already planned,
+// and a function releasing the reference. Reports false if the upload isn't kept anymore.
+func (s *spoolRegistry) acquire(id string) (Opener, []chunkJob, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, ok := s.spools[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	sp.refs++
+
+	var once sync.Once
+
+	return sp.f, sp.chunks, func() {
+		once.Do(func() {
+			s.release(id)
+		})
+	}, true
+}
+
+// release drops a reference to the upload of an item. Upload is removed with the last reference.
+func (s *spoolRegistry) release(id string) {
+	s.mu.Lock()
+
+	sp, ok := s.spools[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+
+	sp.refs--
+	if sp.refs > 0 {
+		s.mu.Unlock()
+		return
+	}
+
+	delete(s.spools, id)
+	s.mu.Unlock()
+
+	if sp.close != nil {
+		sp.close()
+	}
+}
+
+// spoolOpener returns opener of a chunk read from the upload.
+func spoolOpener(f Opener, c chunkJob) func() (io.ReadSeekCloser, error) {
+	return func() (io.ReadSeekCloser, error) {
+		file, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		return spoolFile{
+			SectionReader: io.NewSectionReader(file, c.Start, c.Size()),
+			Closer:        file,
+		}, nil
+	}
+}
+
+// spoolFile is a chunk section of the upload.
+type spoolFile struct {
+	*io.SectionReader
+	io.Closer
+}
+
+// spoolReader is a stream of a pending item, holding reference to the upload until it's closed.
+type spoolReader struct {
+	ContentReader
+	release func()
+}
+
+func (s spoolReader) Close() error {
+	defer s.release()
+
+	return s.ContentReader.Close()
+}
@@ -0,0 +1,507 @@
+package item_usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/file_server_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/chunk_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
+	"github.com/PavelKhripkov/object_storage/pkg/frame"
+	"github.com/pkg/errors"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// TODO better get from config
+const (
+	maxChunkAttempts = 5
+	retryBaseDelay   = time.Second
+	retryMaxDelay    = 30 * time.Second
+	storeTimeout     = time.Hour
+)
+
+var errNoFileServer = errors.New("no file server with enough free space")
+
+// chunkJob represents a chunk to be stored on a file server.
+type chunkJob struct {
+	Position      uint8
+	Start, End    int64
+	Attempts      int
+	FileServiceID string
+	FilePath      string
+	StoredSize    int64
+	// FailedOn holds file servers the chunk couldn't be stored on.
+	FailedOn map[string]bool
+}
+
+// Size returns size of the chunk.
+func (s chunkJob) Size() int64 {
+	return s.End - s.Start + 1
+}
+
+// chunkResult is a reply of store worker.
+type chunkResult struct {
+	job chunkJob
+	err error
+}
+
+// store runs in background and performs:
+// 1. item splitting into chunks;
+// 2. getting available file servers;
+// 3. storing item chunks on file servers.
+// Failed chunks are retried with exponential backoff, on another file server when possible.
+// Item gets ok status once all chunks are stored, or fail status when a chunk runs out of attempts
+// or storing takes longer than storeTimeout.
+func (s *Usecase) store(ctx context.Context, itm item_model.Item, dto StoreItemDTO, dataKey []byte) {
+	s.l.Infof("Storing file %s, of size %d bytes.", dto.Name, dto.Size)
+
+	// Upload is kept until storing is finished and downloads of pending item are done with it.
+	defer s.spools.release(itm.ID)
+
+	// Codecs of all chunks are built the same way, so checking one of them is enough.
+	if _, err := chunkCodec(itm, dataKey, 0); err != nil {
+		s.l.Error(err)
+		s.failItem(ctx, itm)
+		return
+	}
+
+	chunkJobs, err := s.planChunks(ctx, dto.Size)
+	if err != nil {
+		s.l.Error(err)
+		s.failItem(ctx, itm)
+		return
+	}
+
+	s.progress.setChunks(itm.ID, chunkJobs)
+	s.spools.setChunks(itm.ID, chunkJobs)
+
+	// Transfers are bounded by deadline, while models are updated with parent context.
+	transferCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	hashes := s.hashUpload(transferCtx, itm.ID, dto.F)
+
+	results := make(chan chunkResult, len(chunkJobs))
+	// Every chunk has at most one pending retry, so senders never block.
+	retries := make(chan chunkJob, len(chunkJobs))
+
+	for _, c := range chunkJobs {
+		retries <- c
+	}
+
+	usedServers := make(map[string]int)
+	stored := make([]chunkJob, 0, len(chunkJobs))
+
+	var (
+		inFlight    int
+		storedSize  int64
+		terminalErr error
+	)
+
+	for len(stored) < len(chunkJobs) && terminalErr == nil {
+		select {
+		// New job or the one to be retried.
+		case c := <-retries:
+			c.Attempts++
+
+			if err := s.startChunk(transferCtx, itm, dto.F, dataKey, &c, usedServers, results); err != nil {
+				terminalErr = s.retryChunk(itm.ID, c, err, retries)
+				continue
+			}
+
+			inFlight++
+		case res := <-results:
+			inFlight--
+			c := res.job
+
+			err := res.err
+			if err == nil {
+				err = s.registerChunk(ctx, itm.ID, c)
+			}
+
+			if err != nil {
+				s.discardFile(ctx, c)
+				usedServers[c.FileServiceID]--
+				c.FailedOn[c.FileServiceID] = true
+				terminalErr = s.retryChunk(itm.ID, c, err, retries)
+				continue
+			}
+
+			s.progress.chunkStored(itm.ID, c.Position)
+			storedSize += c.StoredSize
+			stored = append(stored, c)
+		case <-transferCtx.Done():
+			terminalErr = transferCtx.Err()
+		}
+	}
+
+	if terminalErr != nil {
+		s.l.WithError(terminalErr).Errorf("Storing item %s failed.", itm.ID)
+
+		// Workers are reading the upload, so waiting for them before it's closed.
+		cancel()
+		// Files of chunks stored meanwhile are left without chunk models.
+		for ; inFlight > 0; inFlight-- {
+			s.discardFile(ctx, (<-results).job)
+		}
+		<-hashes
+
+		s.discardChunks(ctx, itm.ID)
+		s.failItem(ctx, itm)
+		return
+	}
+
+	chunkCount := uint8(len(chunkJobs))
+	hash := <-hashes
+
+	// Now can store chunk info into item model.
+	changeItemParams := item_service.UpdateItemDTO{
+		Status:     item_model.ItemStatusOK.Pointer(),
+		ChunkCount: &chunkCount,
+		StoredSize: &storedSize,
+	}
+
+	if hash != "" {
+		changeItemParams.Hash = &hash
+	}
+
+	if err = s.updateItem(ctx, itm, changeItemParams); err != nil {
+		s.l.Error(err)
+		s.progress.finish(itm.ID, item_model.ItemStatusFail)
+		return
+	}
+
+	s.progress.finish(itm.ID, item_model.ItemStatusOK)
+}
+
+// planChunks splits item into chunk jobs. Number of chunks is limited by number of available file servers.
+func (s *Usecase) planChunks(ctx context.Context, size int64) ([]chunkJob, error) {
+	fileServerCount, err := s.fileServerService.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileServerCount < 1 {
+		return nil, errors.New("no available file servers found")
+	}
+
+	partsCount := defaultPartsCount
+
+	// If there are too few available file servers, we're reducing target chunk amount.
+	if partsCount > fileServerCount {
+		partsCount = fileServerCount
+	}
+
+	chunkPositions, err := s.fileSplitService.SplitFileBySize(size, partsCount)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]chunkJob, len(chunkPositions))
+
+	for i, c := range chunkPositions {
+		newChunkJob := chunkJob{
+			Position: uint8(i),
+			Start:    c,
+			FailedOn: make(map[string]bool),
+		}
+
+		if i != len(chunkPositions)-1 {
+			newChunkJob.End = chunkPositions[i+1] - 1
+		} else {
+			newChunkJob.End = size - 1
+		}
+
+		res[i] = newChunkJob
+	}
+
+	return res, nil
+}
+
+// startChunk chooses file server for a chunk and starts store worker.
+func (s *Usecase) startChunk(
+	ctx context.Context,
+	itm item_model.Item,
+	f Opener,
+	dataKey []byte,
+	c *chunkJob,
+	usedServers map[string]int,
+	results chan<- chunkResult,
+) error {
+	fileServer, err := s.chooseFileServer(ctx, *c, usedServers)
+	if err != nil {
+		return err
+	}
+
+	codec, err := chunkCodec(itm, dataKey, c.Position)
+	if err != nil {
+		return err
+	}
+
+	c.FileServiceID = fileServer.GetID()
+	usedServers[c.FileServiceID]++
+
+	s.progress.chunkAssigned(itm.ID, c.Position, c.FileServiceID)
+	go s.storeWorker(ctx, itm.ID, f, codec, *c, fileServer, results)
+
+	return nil
+}
+
+// chooseFileServer picks a file server with enough free space for a chunk.
+// Servers the chunk has failed on and servers holding other chunks of the item are avoided while possible.
+func (s *Usecase) chooseFileServer(ctx context.Context, c chunkJob, usedServers map[string]int) (file_server_model.FileServer, error) {
+	avoid := make(map[string]bool)
+	for id, count := range usedServers {
+		if count > 0 {
+			avoid[id] = true
+		}
+	}
+	for id := range c.FailedOn {
+		avoid[id] = true
+	}
+
+	for _, exclude := range []map[string]bool{avoid, c.FailedOn, nil} {
+		fileServer, err := s.fileServerService.ChooseOneExcluding(ctx, exclude)
+		if errors.Is(err, sqlite.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The server with most free space is chosen, so there is no need to check others.
+		if fileServer.GetFreeSpace() < c.Size() {
+			continue
+		}
+
+		return fileServer, nil
+	}
+
+	return nil, errNoFileServer
+}
+
+// retryChunk registers failed attempt and schedules the chunk to be retried after backoff delay.
+// Returns error if the chunk has run out of attempts.
+func (s *Usecase) retryChunk(itemID string, c chunkJob, err error, retries chan<- chunkJob) error {
+	s.l.WithError(err).Warnf("Chunk %d of item %s failed, attempt %d of %d.", c.Position, itemID, c.Attempts, maxChunkAttempts)
+	s.progress.chunkFailed(itemID, c.Position, err)
+
+	if c.Attempts >= maxChunkAttempts {
+		return errors.Wrapf(err, "chunk %d failed after %d attempts", c.Position, c.Attempts)
+	}
+
+	time.AfterFunc(retryDelay(c.Attempts), func() {
+		retries <- c
+	})
+
+	return nil
+}
+
+// registerChunk accounts used space of file server and creates chunk model.
+func (s *Usecase) registerChunk(ctx context.Context, itemID string, c chunkJob) error {
+	err := s.fileServerService.UpdateUsedSpace(ctx, c.FileServiceID, c.StoredSize)
+	if err != nil {
+		return err
+	}
+
+	createParams := chunk_service.CreateChunkDTO{
+		ItemID:       itemID,
+		FileServerID: c.FileServiceID,
+		FilePath:     c.FilePath,
+		Position:     c.Position,
+		Size:         c.Size(),
+		StoredSize:   c.StoredSize,
+	}
+
+	if _, err = s.chunkService.Create(ctx, createParams); err != nil {
+		if err := s.fileServerService.UpdateUsedSpace(ctx, c.FileServiceID, -c.StoredSize); err != nil {
+			s.l.Error(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// discardFile queues file of a chunk, which isn't registered, to be removed from file server.
+// Nothing is queued if the chunk didn't get to storing a file.
+func (s *Usecase) discardFile(ctx context.Context, c chunkJob) {
+	if c.FilePath == "" {
+		return
+	}
+
+	if err := s.chunkService.DeleteFile(ctx, c.FileServiceID, c.FilePath); err != nil {
+		s.l.WithError(err).Errorf("Chunk file %s is left on file server %s.", c.FilePath, c.FileServiceID)
+		return
+	}
+
+	s.wakeDeletions()
+}
+
+// failItem sets fail status of an item.
+func (s *Usecase) failItem(ctx context.Context, itm item_model.Item) {
+	s.progress.finish(itm.ID, item_model.ItemStatusFail)
+
+	err := s.updateItem(ctx, itm, item_service.UpdateItemDTO{Status: item_model.ItemStatusFail.Pointer()})
+	if err != nil {
+		s.l.Error(err)
+	}
+}
+
+// updateItem updates item, retrying on errors, so the item doesn't get stuck in pending status.
+func (s *Usecase) updateItem(ctx context.Context, itm item_model.Item, params item_service.UpdateItemDTO) error {
+	var err error
+
+	for attempt := 1; attempt <= maxChunkAttempts; attempt++ {
+		if _, err = s.itemService.Update(ctx, itm, params); err == nil {
+			return nil
+		}
+
+		s.l.WithError(err).Warnf("Updating item %s failed, attempt %d of %d.", itm.ID, attempt, maxChunkAttempts)
+
+		select {
+		case <-time.After(retryDelay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+// storeWorker waits for transfer slot, stores chunk on file server and replies into results queue.
+func (s *Usecase) storeWorker(
+	ctx context.Context,
+	itemID string,
+	f Opener,
+	codec frame.Codec,
+	c chunkJob,
+	fileServer file_server_model.FileServer,
+	results chan<- chunkResult,
+) {
+	release, err := s.transferService.Acquire(ctx, itemID, fileServer.GetID())
+	if err != nil {
+		results <- chunkResult{job: c, err: err}
+		return
+	}
+
+	s.progress.chunkStarted(itemID, c.Position)
+
+	c.FilePath, c.StoredSize, err = s.transferChunk(ctx, itemID, f, codec, c, fileServer)
+	release()
+
+	results <- chunkResult{job: c, err: err}
+}
+
+// transferChunk reads chunk from the upload and stores it on file server.
+// If codec is provided, chunk is encoded into framed stream before storing.
+// Returns path of the stored file and its size.
+func (s *Usecase) transferChunk(
+	ctx context.Context,
+	itemID string,
+	f Opener,
+	codec frame.Codec,
+	c chunkJob,
+	fileServer file_server_model.FileServer,
+) (string, int64, error) {
+	file, err := f.Open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	var src io.Reader = &progressReader{
+		src: &contextReader{
+			ctx: ctx,
+			src: io.NewSectionReader(file, c.Start, c.Size()),
+		},
+		tracker:  s.progress,
+		itemID:   itemID,
+		position: c.Position,
+	}
+
+	if codec != nil {
+		src = frame.NewEncoder(src, codec, frame.DefaultFrameSize)
+	}
+
+	filePath, written, err := s.fileServerService.StoreChunk(ctx, fileServer, src)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Path is returned, so the partial file is removed.
+	if codec == nil && written != c.Size() {
+		return filePath, written, errors.Errorf("chunk stored partially: %d of %d bytes", written, c.Size())
+	}
+
+	return filePath, written, nil
+}
+
+// hashUpload computes SHA-256 of the upload in background, while chunks are being transferred.
+// Hex encoded hash is sent to the returned channel, empty string if hashing failed.
+func (s *Usecase) hashUpload(ctx context.Context, itemID string, f Opener) <-chan string {
+	res := make(chan string, 1)
+
+	go func() {
+		hash, err := s.hashFile(ctx, f)
+		if err != nil {
+			s.l.WithError(err).Warnf("Hashing item %s failed.", itemID)
+		}
+
+		res <- hash
+	}()
+
+	return res
+}
+
+func (s *Usecase) hashFile(ctx context.Context, f Opener) (string, error) {
+	file, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, &contextReader{ctx: ctx, src: file}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// retryDelay returns exponential backoff delay for the attempt.
+// Jitter is added, so chunks failed at once aren't retried at once.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// contextReader fails reading once context is done, so transfers can be aborted.
+type contextReader struct {
+	ctx context.Context
+	src io.Reader
+}
+
+func (s *contextReader) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return s.src.Read(p)
+}
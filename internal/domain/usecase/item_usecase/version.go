@@ -0,0 +1,151 @@
+package item_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+var (
+	ErrInvalidVersion = errors.New("invalid version")
+	ErrItemVersioned  = errors.New("items of versioned containers can't be renamed or moved, copy them instead")
+)
+
+// Resolve returns the version of an item, the latest stored one if version is empty. Item is any version of it.
+// Versions newer than the latest stored one, e.g. pending ones, are returned when requested by ID.
+// Returns ErrItemNotFound if the version is a delete marker. Items of unversioned containers are returned as is.
+func (s *Usecase) Resolve(ctx context.Context, id, version string) (item_model.Item, error) {
+	itm, versioned, err := s.getVersioned(ctx, id)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	if version != "" {
+		if itm, err = s.version(ctx, itm, versioned, version); err != nil {
+			return item_model.Item{}, err
+		}
+
+		if itm.DeleteMarker {
+			return item_model.Item{}, ErrItemNotFound
+		}
+
+		return itm, nil
+	}
+
+	if !versioned {
+		return itm, nil
+	}
+
+	versions, err := s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	for _, v := range versions {
+		if v.Status != item_model.ItemStatusOK {
+			continue
+		}
+
+		if itm.Version > v.Version {
+			return itm, nil
+		}
+
+		if v.DeleteMarker {
+			return item_model.Item{}, ErrItemNotFound
+		}
+
+		return v, nil
+	}
+
+	// Nothing is stored yet.
+	return itm, nil
+}
+
+// Versions returns all versions of an item, delete markers included, the latest ones first.
+// Item of unversioned container is its only version.
+func (s *Usecase) Versions(ctx context.Context, id string) ([]item_model.Item, error) {
+	itm, versioned, err := s.getVersioned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !versioned {
+		return []item_model.Item{itm}, nil
+	}
+
+	return s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+}
+
+// version returns version of the item by its number, delete markers included.
+func (s *Usecase) version(ctx context.Context, itm item_model.Item, versioned bool, version string) (item_model.Item, error) {
+	number, err := strconv.Atoi(version)
+	if err != nil || number < 0 {
+		return item_model.Item{}, errors.Wrapf(ErrInvalidVersion, "%q", version)
+	}
+
+	if itm.Version == number {
+		return itm, nil
+	}
+
+	if !versioned {
+		return item_model.Item{}, ErrItemNotFound
+	}
+
+	versions, err := s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	for _, v := range versions {
+		if v.Version == number {
+			return v, nil
+		}
+	}
+
+	return item_model.Item{}, ErrItemNotFound
+}
+
+// getVersioned returns item and reports whether its container is versioned.
+func (s *Usecase) getVersioned(ctx context.Context, id string) (item_model.Item, bool, error) {
+	itm, err := s.getItem(ctx, id)
+	if err != nil {
+		return item_model.Item{}, false, err
+	}
+
+	versioned, err := s.isVersioned(ctx, itm)
+	if err != nil {
+		return item_model.Item{}, false, err
+	}
+
+	return itm, versioned, nil
+}
+
+// isVersioned reports whether container of the item is versioned.
+func (s *Usecase) isVersioned(ctx context.Context, itm item_model.Item) (bool, error) {
+	cont, err := s.containerService.Get(ctx, itm.ContainerID)
+	if err != nil && !errors.Is(err, sqlite.ErrNotFound) {
+		return false, err
+	}
+
+	return cont.Versioning, nil
+}
+
+// getContainer returns container of items, ErrContainerNotFound if there is no such one or it's in trash.
+func (s *Usecase) getContainer(ctx context.Context, id string) (container_model.Container, error) {
+	cont, err := s.containerService.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return container_model.Container{}, ErrContainerNotFound
+		}
+		return container_model.Container{}, err
+	}
+
+	if cont.Trashed != nil {
+		return container_model.Container{}, ErrContainerNotFound
+	}
+
+	return cont, nil
+}
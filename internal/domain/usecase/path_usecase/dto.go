@@ -0,0 +1,24 @@
+package path_usecase
+
+import (
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"io"
+)
+
+// Entry is an entity found by path, either item or container.
+type Entry struct {
+	Item      *item_model.Item           `json:"item,omitempty"`
+	Container *container_model.Container `json:"container,omitempty"`
+}
+
+// PutItemDTO specifies item to be stored by path.
+type PutItemDTO struct {
+	Path string
+	Body io.Reader
+	// ContentType is a declared media type, detected from the content if empty or unspecific.
+	ContentType string
+	Metadata    item_model.Metadata
+	// Parents requests missing containers on the path to be created.
+	Parents bool
+}
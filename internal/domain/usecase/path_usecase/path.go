@@ -0,0 +1,265 @@
+package path_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/container_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/container_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// replaceTimeout limits waiting for an item put by path to be stored, before it replaces items with the same path.
+const replaceTimeout = 24 * time.Hour
+
+var (
+	ErrNotFound    = errors.New("path not found")
+	ErrInvalidPath = errors.New("invalid path")
+)
+
+// Usecase addresses containers and items by paths. Path is a chain of container names from a root container,
+// ending with item or container name, e.g. "projects/2024/report.pdf".
+type Usecase struct {
+	containerService *container_service.Service
+	itemService      *item_service.Service
+	itemUsecase      *item_usecase.Usecase
+	l                *log.Entry
+}
+
+// NewPathUsecase creates new path use cases service.
+func NewPathUsecase(containerService *container_service.Service, itemService *item_service.Service, itemUsecase *item_usecase.Usecase, l *log.Logger) *Usecase {
+	return &Usecase{
+		containerService: containerService,
+		itemService:      itemService,
+		itemUsecase:      itemUsecase,
+		l:                l.WithField("component", "PathUsecase"),
+	}
+}
+
+// Resolve returns item found by path, or container if there is no such item.
+// Path of several items with the same name resolves to the latest one which can be downloaded, unless a version
+// of the item is specified. Item hidden behind a delete marker isn't found.
+func (s *Usecase) Resolve(ctx context.Context, p, version string) (Entry, error) {
+	names, err := splitPath(p)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	dir, name := names[:len(names)-1], names[len(names)-1]
+
+	var parentID string
+
+	if len(dir) > 0 {
+		parent, err := s.resolveContainers(ctx, dir, false)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		parentID = parent.ID
+
+		var itm item_model.Item
+		var ok bool
+
+		if version != "" {
+			itm, ok, err = s.itemVersion(ctx, parentID, name, version)
+		} else {
+			itm, ok, err = s.latestItem(ctx, parentID, name)
+		}
+
+		if err != nil {
+			return Entry{}, err
+		}
+
+		if ok {
+			return Entry{Item: &itm}, nil
+		}
+	}
+
+	cont, err := s.containerService.GetByName(ctx, parentID, name)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrNotFound) {
+			return Entry{}, ErrNotFound
+		}
+		return Entry{}, err
+	}
+
+	return Entry{Container: &cont}, nil
+}
+
+// Put stores item by path. Once it's stored, items with the same path put before are deleted, so the path is
+// overwritten. If storing fails, they're kept. In versioned containers the item is a new version instead. Missing containers are created if requested, with settings of their parent.
+func (s *Usecase) Put(ctx context.Context, dto PutItemDTO) (item_model.Item, error) {
+	names, err := splitPath(dto.Path)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	if len(names) < 2 {
+		return item_model.Item{}, errors.Wrap(ErrInvalidPath, "item must be put into a container")
+	}
+
+	parent, err := s.resolveContainers(ctx, names[:len(names)-1], dto.Parents)
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	itm, err := s.itemUsecase.Import(ctx, item_usecase.ImportItemDTO{
+		Body:        dto.Body,
+		Name:        names[len(names)-1],
+		ContentType: dto.ContentType,
+		ContainerID: parent.ID,
+		Metadata:    dto.Metadata,
+	})
+	if err != nil {
+		return item_model.Item{}, err
+	}
+
+	// Versioned containers keep items put before as versions.
+	if !parent.Versioning {
+		// Replacing outlives the request.
+		go s.replace(context.Background(), itm)
+	}
+
+	return itm, nil
+}
+
+// replace waits until the item is stored and moves items with the same name put before it to trash.
+func (s *Usecase) replace(ctx context.Context, itm item_model.Item) {
+	if _, err := s.itemUsecase.WaitStored(ctx, itm.ID, replaceTimeout); err != nil {
+		s.l.WithError(err).Warnf("Item %s doesn't replace items with the same path.", itm.ID)
+		return
+	}
+
+	items, err := s.itemService.ListByName(ctx, itm.ContainerID, itm.Name)
+	if err != nil {
+		s.l.Error(err)
+		return
+	}
+
+	for _, old := range items {
+		// Items put later replace this one themselves.
+		if old.ID == itm.ID || old.Created.After(itm.Created) {
+			continue
+		}
+
+		err = s.itemUsecase.Remove(ctx, old.ID, "", false)
+		if err != nil && !errors.Is(err, item_usecase.ErrItemNotFound) {
+			s.l.WithError(err).Warnf("Item %s isn't replaced by item %s.", old.ID, itm.ID)
+		}
+	}
+}
+
+// resolveContainers walks container tree from the root by names and returns the last container.
+// Missing containers are created if create is set.
+func (s *Usecase) resolveContainers(ctx context.Context, names []string, create bool) (container_model.Container, error) {
+	var parent container_model.Container
+
+	for _, name := range names {
+		cont, err := s.containerService.GetByName(ctx, parent.ID, name)
+		if errors.Is(err, sqlite.ErrNotFound) && create {
+			cont, err = s.createContainer(ctx, parent, name)
+		}
+
+		if err != nil {
+			if errors.Is(err, sqlite.ErrNotFound) {
+				return container_model.Container{}, errors.Wrapf(ErrNotFound, "container %q", name)
+			}
+			return container_model.Container{}, err
+		}
+
+		parent = cont
+	}
+
+	return parent, nil
+}
+
+// createContainer creates child container of the parent, taking settings of the parent.
+// Parent is empty for root containers. Container created concurrently by another request is returned as well.
+func (s *Usecase) createContainer(ctx context.Context, parent container_model.Container, name string) (container_model.Container, error) {
+	compression := parent.Compression
+	if compression == "" {
+		compression = item_model.CompressionNone
+	}
+
+	res, err := s.containerService.Create(ctx, container_service.CreateContainerDTO{
+		Name:         name,
+		ParentID:     parent.ID,
+		Compression:  compression,
+		Encryption:   parent.Encryption,
+		CacheControl: parent.CacheControl,
+		RateLimit:    parent.RateLimit,
+		Versioning:   parent.Versioning,
+	})
+	if err != nil {
+		// Unique name within the parent is violated by concurrent creation.
+		if existing, getErr := s.containerService.GetByName(ctx, parent.ID, name); getErr == nil {
+			return existing, nil
+		}
+		return container_model.Container{}, err
+	}
+
+	return res, nil
+}
+
+// latestItem returns the latest item of the container with the name which can be downloaded:
+// either stored one or pending one uploaded to this server. Nothing is found if it's a delete marker.
+func (s *Usecase) latestItem(ctx context.Context, containerID, name string) (item_model.Item, bool, error) {
+	items, err := s.itemService.ListByName(ctx, containerID, name)
+	if err != nil {
+		return item_model.Item{}, false, err
+	}
+
+	for _, itm := range items {
+		switch itm.Status {
+		case item_model.ItemStatusOK:
+			return itm, !itm.DeleteMarker, nil
+		case item_model.ItemStatusPending:
+			if _, err = s.itemUsecase.Stat(ctx, itm.ID); err == nil {
+				return itm, true, nil
+			}
+		}
+	}
+
+	return item_model.Item{}, false, nil
+}
+
+// itemVersion returns version of the item of the container with the name.
+func (s *Usecase) itemVersion(ctx context.Context, containerID, name, version string) (item_model.Item, bool, error) {
+	items, err := s.itemService.ListByName(ctx, containerID, name)
+	if err != nil || len(items) == 0 {
+		return item_model.Item{}, false, err
+	}
+
+	itm, err := s.itemUsecase.Resolve(ctx, items[0].ID, version)
+	if err != nil {
+		if errors.Is(err, item_usecase.ErrItemNotFound) {
+			return item_model.Item{}, false, nil
+		}
+		return item_model.Item{}, false, err
+	}
+
+	return itm, true, nil
+}
+
+// splitPath splits path into names. Leading and trailing slashes are ignored.
+func splitPath(p string) ([]string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil, errors.Wrap(ErrInvalidPath, "path is empty")
+	}
+
+	names := strings.Split(p, "/")
+
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." {
+			return nil, errors.Wrapf(ErrInvalidPath, "%q", p)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,31 @@
+package presign_usecase
+
+import "time"
+
+// PresignDTO specifies URL to be signed: either download of an item with GET method,
+// or upload into a container with POST method. Upload size can be limited.
+type PresignDTO struct {
+	Method      string `json:"method"`
+	ItemID      string `json:"item_id,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	// ExpiresIn is a duration the URL is valid for, e.g. "1h".
+	ExpiresIn string `json:"expires_in,omitempty"`
+	// Disposition of downloaded content, "inline" lets browsers display it.
+	Disposition string `json:"disposition,omitempty"`
+	MinSize     int64  `json:"min_size,omitempty"`
+	MaxSize     int64  `json:"max_size,omitempty"`
+}
+
+// PresignedURL represents signed URL, relative to the gateway address.
+type PresignedURL struct {
+	Method  string    `json:"method"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// UploadGrant represents upload allowed by a signed URL. Zero sizes aren't limited.
+type UploadGrant struct {
+	ContainerID string
+	MinSize     int64
+	MaxSize     int64
+}
@@ -0,0 +1,188 @@
+package presign_usecase
+
+import (
+	"context"
+	"github.com/PavelKhripkov/object_storage/internal/adapter/db/sqlite"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/container_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/item_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/presign_service"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultExpiry is used if expiry of URL isn't specified.
+	DefaultExpiry = 15 * time.Minute
+	// MaxExpiry limits expiry of URLs.
+	MaxExpiry = 7 * 24 * time.Hour
+
+	storePath = "/item/store"
+)
+
+var (
+	ErrInvalidRequest    = errors.New("invalid presign request")
+	ErrItemNotFound      = errors.New("item not found")
+	ErrContainerNotFound = errors.New("container not found")
+)
+
+// Usecase represents presigned URL use cases.
+type Usecase struct {
+	itemService      *item_service.Service
+	containerService *container_service.Service
+	presignService   *presign_service.Service
+	l                *log.Entry
+}
+
+// NewPresignUsecase creates new presigned URL use cases service.
+func NewPresignUsecase(
+	itemService *item_service.Service,
+	containerService *container_service.Service,
+	presignService *presign_service.Service,
+	l *log.Logger) *Usecase {
+	return &Usecase{
+		itemService:      itemService,
+		containerService: containerService,
+		presignService:   presignService,
+		l:                l.WithField("component", "PresignUsecase"),
+	}
+}
+
+// Presign issues URL for download of an existing item or for upload into an existing container.
+func (s *Usecase) Presign(ctx context.Context, dto PresignDTO) (PresignedURL, error) {
+	if !s.presignService.Enabled() {
+		return PresignedURL{}, presign_service.ErrDisabled
+	}
+
+	expiresIn := DefaultExpiry
+
+	if dto.ExpiresIn != "" {
+		var err error
+
+		expiresIn, err = time.ParseDuration(dto.ExpiresIn)
+		if err != nil {
+			return PresignedURL{}, errors.Wrapf(ErrInvalidRequest, "expires_in %q", dto.ExpiresIn)
+		}
+	}
+
+	if expiresIn <= 0 || expiresIn > MaxExpiry {
+		return PresignedURL{}, errors.Wrapf(ErrInvalidRequest, "expiry must be positive and not longer than %s", MaxExpiry)
+	}
+
+	method := strings.ToUpper(dto.Method)
+	params := make(url.Values)
+
+	var path string
+
+	switch method {
+	case http.MethodGet:
+		if dto.ItemID == "" || dto.ContainerID != "" || dto.MinSize != 0 || dto.MaxSize != 0 {
+			return PresignedURL{}, errors.Wrap(ErrInvalidRequest, "download URL takes item_id only")
+		}
+
+		itm, err := s.itemService.Get(ctx, dto.ItemID)
+		if errors.Is(err, sqlite.ErrNotFound) || (err == nil && itm.Trashed != nil) {
+			return PresignedURL{}, ErrItemNotFound
+		}
+
+		if err != nil {
+			return PresignedURL{}, err
+		}
+
+		path = downloadPath(dto.ItemID)
+
+		if dto.Disposition != "" && dto.Disposition != "attachment" && dto.Disposition != "inline" {
+			return PresignedURL{}, errors.Wrapf(ErrInvalidRequest, "disposition %q", dto.Disposition)
+		}
+
+		if dto.Disposition != "" {
+			params.Set("disposition", dto.Disposition)
+		}
+	case http.MethodPost:
+		if dto.ContainerID == "" || dto.ItemID != "" || dto.Disposition != "" {
+			return PresignedURL{}, errors.Wrap(ErrInvalidRequest, "upload URL takes container_id and optional size limits")
+		}
+
+		if dto.MinSize < 0 || dto.MaxSize < 0 || (dto.MaxSize > 0 && dto.MinSize > dto.MaxSize) {
+			return PresignedURL{}, errors.Wrap(ErrInvalidRequest, "size limits")
+		}
+
+		cont, err := s.containerService.Get(ctx, dto.ContainerID)
+		if errors.Is(err, sqlite.ErrNotFound) || (err == nil && cont.Trashed != nil) {
+			return PresignedURL{}, ErrContainerNotFound
+		}
+
+		if err != nil {
+			return PresignedURL{}, err
+		}
+
+		path = storePath
+		params.Set("container_id", dto.ContainerID)
+
+		if dto.MinSize > 0 {
+			params.Set("min_size", strconv.FormatInt(dto.MinSize, 10))
+		}
+
+		if dto.MaxSize > 0 {
+			params.Set("max_size", strconv.FormatInt(dto.MaxSize, 10))
+		}
+	default:
+		return PresignedURL{}, errors.Wrapf(ErrInvalidRequest, "method %q can't be presigned", dto.Method)
+	}
+
+	expires := time.Now().Add(expiresIn).Truncate(time.Second)
+
+	query, err := s.presignService.Sign(method, path, params, expires)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+
+	return PresignedURL{
+		Method:  method,
+		URL:     path + "?" + query.Encode(),
+		Expires: expires,
+	}, nil
+}
+
+// VerifyDownload checks that query is signed for download of the item. HEAD is allowed by download URLs too.
+func (s *Usecase) VerifyDownload(itemID string, query url.Values) error {
+	return s.presignService.Verify(http.MethodGet, downloadPath(itemID), query)
+}
+
+// VerifyUpload checks that query is signed for upload and returns what is allowed to be uploaded.
+func (s *Usecase) VerifyUpload(query url.Values) (UploadGrant, error) {
+	if err := s.presignService.Verify(http.MethodPost, storePath, query); err != nil {
+		return UploadGrant{}, err
+	}
+
+	res := UploadGrant{ContainerID: query.Get("container_id")}
+
+	var err error
+
+	if value := query.Get("min_size"); value != "" {
+		if res.MinSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return UploadGrant{}, err
+		}
+	}
+
+	if value := query.Get("max_size"); value != "" {
+		if res.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return UploadGrant{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// IsSigned reports whether query carries signature, so it must be verified.
+func IsSigned(query url.Values) bool {
+	return query.Has(presign_service.SignatureParam)
+}
+
+func downloadPath(itemID string) string {
+	return "/item/" + url.PathEscape(itemID) + "/download"
+}
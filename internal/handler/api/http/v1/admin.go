@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"encoding/json"
+	item_usecase "github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+type adminHandler struct {
+	itemUsecase *item_usecase.Usecase
+	l           *log.Entry
+}
+
+func NewAdminHandler(itemUsecase *item_usecase.Usecase, l *log.Logger) Handler {
+	return &adminHandler{
+		itemUsecase: itemUsecase,
+		l:           l.WithField("component", "AdminHandler"),
+	}
+}
+
+func (s adminHandler) Register(router *httprouter.Router) {
+	router.POST("/admin/keys/rotate", s.RotateKeys)
+	router.GET("/admin/transfers", s.Transfers)
+	router.GET("/admin/cache", s.Cache)
+}
+
+// RotateKeys re-wraps item data keys with the active master key.
+func (s adminHandler) RotateKeys(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	rotated, err := s.itemUsecase.RotateKeys(r.Context())
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(map[string]int{"rotated": rotated})
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Transfers replies with active and queued chunk transfers.
+func (s adminHandler) Transfers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	bytes, err := json.Marshal(s.itemUsecase.TransferStats())
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Cache replies with chunk cache hit and miss stats.
+func (s adminHandler) Cache(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	stats, ok := s.itemUsecase.CacheStats()
+	if !ok {
+		http.Error(w, "chunk cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	bytes, err := json.Marshal(stats)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
@@ -2,11 +2,15 @@ package v1
 
 import (
 	"encoding/json"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/page_model"
 	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/container_usecase"
 	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 type containerHandler struct {
@@ -22,15 +26,36 @@ func NewContainerHandler(usecase *container_usecase.Usecase, l *log.Logger) Hand
 }
 
 func (s containerHandler) Register(router *httprouter.Router) {
-	router.POST("/container/create", s.Create)
+	// Router doesn't allow static path segments next to parameters, so create is dispatched by post.
+	router.POST("/container/:id", s.post)
+	router.POST("/container/:id/restore", s.Restore)
 	router.GET("/container/:id", s.Get)
+	router.PATCH("/container/:id", s.Update)
 	router.GET("/container", s.List)
+	router.GET("/container/:id/items", s.Items)
+	router.GET("/container/:id/archive", s.Archive)
 	router.DELETE("/container/:id/delete", s.Delete)
+	router.GET("/container/:id/deletion", s.Deletion)
+}
+
+// post dispatches POST requests of /container/create.
+func (s containerHandler) post(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if params.ByName("id") != "create" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.Create(w, r, params)
 }
 
 // Get replies with a single entity of container.
 func (s containerHandler) Get(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	res, err := s.containerUsecase.Get(r.Context(), params.ByName("id"))
+	if errors.Is(err, container_usecase.ErrContainerNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -86,9 +111,73 @@ func (s containerHandler) Create(w http.ResponseWriter, r *http.Request, params
 	}
 }
 
-// List returns all containers.
+// Update renames container, moves it to another parent or turns on versioning. Body specifies fields to be changed:
+// name, parent_id and versioning, empty parent_id makes container a root one.
+func (s containerHandler) Update(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	var dto container_usecase.UpdateContainerDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.containerUsecase.Update(r.Context(), params.ByName("id"), dto)
+
+	switch {
+	case errors.Is(err, container_usecase.ErrContainerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, container_usecase.ErrInvalidName), errors.Is(err, container_usecase.ErrParentNotFound),
+		errors.Is(err, container_usecase.ErrVersioningOn):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, container_usecase.ErrContainerExists), errors.Is(err, container_usecase.ErrContainerCycle):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// List replies with a page of containers. Query parameters sort (name or created), order (asc or desc), prefix of name,
+// limit and cursor of the page are optional. "trashed=true" lists containers in trash.
 func (s containerHandler) List(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	res, err := s.containerUsecase.List(r.Context())
+	pageParams, err := parsePageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trashed, err := parseQueryBool(r, "trashed")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.containerUsecase.ListPage(r.Context(), trashed, pageParams)
+	if errors.Is(err, page_model.ErrInvalidParams) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
 		s.l.Error(err)
@@ -108,6 +197,277 @@ func (s containerHandler) List(w http.ResponseWriter, r *http.Request, params ht
 	}
 }
 
+// Items replies with a page of container items. Besides parameters of List, items can be sorted by size
+// and filtered by status and metadata, e.g. "meta-build=42".
+func (s containerHandler) Items(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	pageParams, err := parsePageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trashed, err := parseQueryBool(r, "trashed")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := parseMetaValues(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := item_model.ListFilter{
+		Status:   item_model.Status(r.URL.Query().Get("status")),
+		Metadata: metadata,
+		Trashed:  trashed,
+	}
+
+	res, err := s.containerUsecase.ListItems(r.Context(), params.ByName("id"), filter, pageParams)
+
+	switch {
+	case errors.Is(err, container_usecase.ErrContainerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, page_model.ErrInvalidParams):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Archive streams items of the container as a single archive.
+// Query parameter format selects zip (default) or tar.gz, recursive includes child containers,
+// rate limits reading of items, bytes per second.
+func (s containerHandler) Archive(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	format := container_usecase.ArchiveFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = container_usecase.ArchiveFormatZip
+	}
+
+	recursive, err := parseQueryBool(r, "recursive")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := format.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rate, err := parseRate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cont, err := s.containerUsecase.Get(r.Context(), params.ByName("id"))
+	if errors.Is(err, container_usecase.ErrContainerNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/zip"
+	if format == container_usecase.ArchiveFormatTarGz {
+		contentType = "application/gzip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(cont.Name+"."+string(format)))
+
+	// Headers are sent already, so errors can only be logged. Client gets truncated archive.
+	if err = s.containerUsecase.Archive(r.Context(), cont, format, recursive, rate, w); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Delete moves empty container to trash, with recursive query parameter the container is moved together
+// with its child containers and items. With permanent query parameter, deletes empty container permanently,
+// or starts background job deleting it with all its child containers and items if recursive, replies with the job.
 func (s containerHandler) Delete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	// TODO implement.
+	recursive, err := parseQueryBool(r, "recursive")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	permanent, err := parseQueryBool(r, "permanent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !permanent {
+		err = s.containerUsecase.Trash(r.Context(), params.ByName("id"), recursive)
+
+		switch {
+		case errors.Is(err, container_usecase.ErrContainerNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, container_usecase.ErrContainerNotEmpty):
+			http.Error(w, err.Error()+", delete it recursively", http.StatusConflict)
+		case errors.Is(err, container_usecase.ErrContainerBusy):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			s.l.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return
+	}
+
+	if !recursive {
+		err := s.containerUsecase.Delete(r.Context(), params.ByName("id"))
+
+		switch {
+		case errors.Is(err, container_usecase.ErrContainerNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, container_usecase.ErrContainerNotEmpty):
+			http.Error(w, err.Error()+", delete it recursively", http.StatusConflict)
+		case err != nil:
+			s.l.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return
+	}
+
+	job, err := s.containerUsecase.DeleteRecursive(r.Context(), params.ByName("id"))
+	if err != nil {
+		if errors.Is(err, container_usecase.ErrContainerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(job)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/container/"+job.ContainerID+"/deletion")
+	w.WriteHeader(http.StatusAccepted)
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Restore takes container out of trash together with content deleted with it, replies with the container.
+func (s containerHandler) Restore(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	res, err := s.containerUsecase.Restore(r.Context(), params.ByName("id"))
+
+	switch {
+	case errors.Is(err, container_usecase.ErrContainerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, container_usecase.ErrContainerNotTrashed), errors.Is(err, container_usecase.ErrParentTrashed),
+		errors.Is(err, container_usecase.ErrContainerExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Deletion replies with state of the latest recursive deletion of the container.
+func (s containerHandler) Deletion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	job, ok := s.containerUsecase.DeletionJob(params.ByName("id"))
+	if !ok {
+		http.Error(w, "no deletion job of the container", http.StatusNotFound)
+		return
+	}
+
+	bytes, err := json.Marshal(job)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// parseQueryBool reads boolean query parameter, false if it's absent.
+func parseQueryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrap(err, name)
+	}
+
+	return res, nil
+}
+
+// parsePageParams reads list parameters from query: sort, order, prefix, cursor and limit.
+func parsePageParams(r *http.Request) (page_model.Params, error) {
+	query := r.URL.Query()
+
+	res := page_model.Params{
+		Sort:   page_model.Sort(query.Get("sort")),
+		Order:  page_model.Order(query.Get("order")),
+		Prefix: query.Get("prefix"),
+		Cursor: query.Get("cursor"),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return page_model.Params{}, errors.Errorf("invalid limit %q", value)
+		}
+
+		res.Limit = limit
+	}
+
+	return res, nil
 }
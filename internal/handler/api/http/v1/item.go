@@ -2,8 +2,12 @@ package v1
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
 	item_usecase "github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/presign_usecase"
 	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"mime"
@@ -17,29 +21,70 @@ import (
 const MaxFileSize = 10 * 1024 * 1024 * 1024  // 10 Gb
 const MaxMultiPartMemory = 100 * 1024 * 1024 // 100 Mb
 
+// PresignedFormOverhead is allowed on top of presigned upload size limit for the rest of multipart form.
+const PresignedFormOverhead = 1024 * 1024 // 1 Mb
+
+// MetaHeaderPrefix is a prefix of headers carrying item metadata, e.g. "X-Meta-Build: 42".
+const MetaHeaderPrefix = "X-Meta-"
+
+// MetaFieldPrefix is a prefix of form fields and query parameters carrying item metadata, e.g. "meta-build=42".
+const MetaFieldPrefix = "meta-"
+
+// WaitHeader is a header requesting upload to wait until item is durably stored, same as "wait" query parameter.
+const WaitHeader = "X-Wait-Durable"
+
 type itemHandler struct {
-	itemUsecase *item_usecase.Usecase
-	l           *log.Entry
+	itemUsecase    *item_usecase.Usecase
+	presignUsecase *presign_usecase.Usecase
+	waitTimeout    time.Duration
+	l              *log.Entry
 }
 
-func NewItemHandler(usecase *item_usecase.Usecase, l *log.Logger) Handler {
+// NewItemHandler creates item handler. Uploads waiting for durability are limited by waitTimeout by default.
+// Requests carrying signature are verified with presign use cases.
+func NewItemHandler(usecase *item_usecase.Usecase, presignUsecase *presign_usecase.Usecase, waitTimeout time.Duration, l *log.Logger) Handler {
 	return &itemHandler{
-		itemUsecase: usecase,
-		l:           l.WithField("component", "ItemHandler"),
+		itemUsecase:    usecase,
+		presignUsecase: presignUsecase,
+		waitTimeout:    waitTimeout,
+		l:              l.WithField("component", "ItemHandler"),
 	}
 }
 
 func (s itemHandler) Register(router *httprouter.Router) {
-	router.POST("/item/store", s.Store)
+	// Router doesn't allow static path segments next to parameters, so store, import and copy are dispatched by post.
+	router.POST("/item/:id", s.post)
+	router.POST("/item/:id/restore", s.Restore)
 	router.GET("/item/:id", s.Get)
+	router.PATCH("/item/:id", s.Update)
+	router.DELETE("/item/:id", s.Delete)
 	router.GET("/item/:id/download", s.Download)
+	router.HEAD("/item/:id/download", s.DownloadHead)
+	router.GET("/item/:id/versions", s.Versions)
+	router.GET("/item/:id/progress", s.Progress)
+	router.GET("/item/:id/events", s.Events)
 }
 
-// Get replies with a single entity of item.
+// post dispatches POST requests of /item/store, /item/import and /item/copy.
+func (s itemHandler) post(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	switch params.ByName("id") {
+	case "store":
+		s.Store(w, r, params)
+	case "import":
+		s.Import(w, r, params)
+	case "copy":
+		s.Copy(w, r, params)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Get replies with a single entity of item. Items of versioned containers are replied with their latest version,
+// "version" query parameter specifies another one.
 func (s itemHandler) Get(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	item, err := s.itemUsecase.Get(r.Context(), params.ByName("id"))
+	item, err := s.itemUsecase.Resolve(r.Context(), params.ByName("id"), r.URL.Query().Get("version"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.replyItemError(w, err)
 		return
 	}
 
@@ -56,7 +101,158 @@ func (s itemHandler) Get(w http.ResponseWriter, r *http.Request, params httprout
 	return
 }
 
+// Versions replies with all versions of item, the latest ones first. Delete markers are included.
+func (s itemHandler) Versions(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	items, err := s.itemUsecase.Versions(r.Context(), params.ByName("id"))
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	bytes, err := json.Marshal(items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Update renames item, moves it to another container or changes its metadata. Body specifies fields to be changed:
+// name, container_id and metadata entries, null entries are removed.
+func (s itemHandler) Update(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	var dto item_usecase.UpdateItemDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.itemUsecase.Update(r.Context(), params.ByName("id"), dto)
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	bytes, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Copy creates a copy of stored item sharing its chunk files, nothing is transferred.
+// Body specifies item_id, and optionally container_id and name of the copy.
+func (s itemHandler) Copy(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	var dto item_usecase.CopyItemDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.itemUsecase.Copy(r.Context(), dto)
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	bytes, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// replyItemError replies with error of item use cases, choosing status code by the error.
+func (s itemHandler) replyItemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, item_usecase.ErrItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, item_usecase.ErrInvalidName), errors.Is(err, item_usecase.ErrContainerNotFound),
+		errors.Is(err, item_model.ErrInvalidMetadata), errors.Is(err, item_usecase.ErrInvalidVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, item_usecase.ErrItemBusy), errors.Is(err, item_usecase.ErrItemNotStored),
+		errors.Is(err, item_usecase.ErrItemVersioned), errors.Is(err, item_usecase.ErrItemNotTrashed),
+		errors.Is(err, item_usecase.ErrContainerTrashed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Delete moves item to trash, "permanent" query parameter deletes it permanently, chunk files are removed
+// from file servers in background then. Items of versioned containers get a delete marker instead of trash,
+// "version" query parameter deletes a specific version permanently.
+func (s itemHandler) Delete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	permanent, err := parseQueryBool(r, "permanent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.itemUsecase.Remove(r.Context(), params.ByName("id"), r.URL.Query().Get("version"), permanent)
+
+	switch {
+	case errors.Is(err, item_usecase.ErrItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, item_usecase.ErrInvalidVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, item_usecase.ErrItemBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Restore takes item out of trash, or removes delete marker hiding versioned item. Replies with restored item.
+func (s itemHandler) Restore(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	item, err := s.itemUsecase.Restore(r.Context(), params.ByName("id"))
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	bytes, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
 // Store parses body into form and passes incoming file to be stored into chunks on file servers.
+// By default replies with pending item at once. If waiting is requested, replies once all chunks are stored.
+// Presigned uploads go into the container of the signed URL and must fit its size limits.
 func (s itemHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	r.Body = http.MaxBytesReader(w, r.Body, MaxFileSize)
 	defer func() {
@@ -65,6 +261,33 @@ func (s itemHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.
 		}
 	}()
 
+	wait, waitTimeout, err := s.parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var grant *presign_usecase.UploadGrant
+
+	if presign_usecase.IsSigned(r.URL.Query()) {
+		verified, err := s.presignUsecase.VerifyUpload(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		if verified.MaxSize > 0 {
+			if r.ContentLength > verified.MaxSize+PresignedFormOverhead {
+				http.Error(w, "upload exceeds allowed size", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, verified.MaxSize+PresignedFormOverhead)
+		}
+
+		grant = &verified
+	}
+
 	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -72,12 +295,22 @@ func (s itemHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.
 	}
 
 	if !strings.HasPrefix(mediaType, "multipart/") {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "multipart form expected", http.StatusBadRequest)
 		return
 	}
 
 	mr := multipart.NewReader(r.Body, params["boundary"])
 	form, err := mr.ReadForm(MaxMultiPartMemory)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	cleanUpForm := func() {
 		if err := form.RemoveAll(); err != nil {
@@ -98,27 +331,158 @@ func (s itemHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.
 	}
 	fileHeader := form.File["item"][0]
 
-	if len(form.Value["container_id"]) != 1 {
+	var containerID string
+
+	switch {
+	case grant != nil && len(form.Value["container_id"]) == 0:
+		containerID = grant.ContainerID
+	case len(form.Value["container_id"]) != 1:
 		defer cleanUpForm()
 		http.Error(w, "accepting exactly one 'container_id' value", http.StatusBadRequest)
 		return
+	default:
+		containerID = form.Value["container_id"][0]
+	}
+
+	if grant != nil {
+		if containerID != grant.ContainerID {
+			defer cleanUpForm()
+			http.Error(w, "container isn't allowed by signed URL", http.StatusForbidden)
+			return
+		}
+
+		if grant.MaxSize > 0 && fileHeader.Size > grant.MaxSize {
+			defer cleanUpForm()
+			http.Error(w, "upload exceeds allowed size", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if fileHeader.Size < grant.MinSize {
+			defer cleanUpForm()
+			http.Error(w, "upload is smaller than allowed size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Optional, container settings are used if not specified.
+	var (
+		compression item_model.Compression
+		encryption  item_model.Encryption
+	)
+
+	if len(form.Value["compression"]) > 0 {
+		compression = item_model.Compression(form.Value["compression"][0])
+	}
+
+	if len(form.Value["encryption"]) > 0 {
+		encryption = item_model.Encryption(form.Value["encryption"][0])
+	}
+
+	// Form fields take precedence over headers.
+	metadata := parseMetaHeaders(r.Header)
+
+	formMetadata, err := parseMetaValues(form.Value)
+	if err != nil {
+		defer cleanUpForm()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for k, v := range formMetadata {
+		metadata[k] = v
 	}
-	containerID := form.Value["container_id"][0]
 
 	dto := item_usecase.StoreItemDTO{
 		F:           fileHeader,
 		Name:        fileHeader.Filename,
+		ContentType: fileHeader.Header.Get("Content-Type"),
 		ContainerID: containerID,
 		Size:        fileHeader.Size,
+		Compression: compression,
+		Encryption:  encryption,
+		Metadata:    metadata,
 		Close:       cleanUpForm,
 	}
 
 	item, err := s.itemUsecase.Store(r.Context(), dto)
 	if err != nil {
+		cleanUpForm()
+
+		code := http.StatusInternalServerError
+		if errors.Is(err, item_model.ErrInvalidMetadata) || errors.Is(err, item_usecase.ErrContainerNotFound) ||
+			errors.Is(err, item_usecase.ErrInvalidName) {
+			code = http.StatusBadRequest
+		}
+
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	s.replyStored(w, r, item, wait, waitTimeout)
+}
+
+// Import pulls item from URL, local path or file server path and stores it into chunks on file servers.
+// Waiting is requested the same way as for Store.
+func (s itemHandler) Import(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	decoder := json.NewDecoder(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	wait, waitTimeout, err := s.parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto item_usecase.ImportItemDTO
+
+	if err = decoder.Decode(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.itemUsecase.Import(r.Context(), dto)
+
+	switch {
+	case errors.Is(err, item_model.ErrInvalidMetadata), errors.Is(err, item_usecase.ErrContainerNotFound),
+		errors.Is(err, item_usecase.ErrInvalidSource), errors.Is(err, item_usecase.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, item_usecase.ErrSourceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		s.l.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	s.replyStored(w, r, item, wait, waitTimeout)
+}
+
+// replyStored replies with just created item, waiting for it to be stored if requested.
+func (s itemHandler) replyStored(w http.ResponseWriter, r *http.Request, item item_model.Item, wait bool, waitTimeout time.Duration) {
+	var err error
+
+	if wait {
+		item, err = s.itemUsecase.WaitStored(r.Context(), item.ID, waitTimeout)
+
+		switch {
+		case errors.Is(err, item_usecase.ErrWaitTimeout):
+			http.Error(w, fmt.Sprintf("item %s: %s", item.ID, err), http.StatusGatewayTimeout)
+			return
+		case errors.Is(err, item_usecase.ErrStoreFailed):
+			http.Error(w, fmt.Sprintf("item %s: %s", item.ID, err), http.StatusBadGateway)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	bytes, err := json.Marshal(item)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -128,20 +492,322 @@ func (s itemHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.
 	if _, err = io.WriteString(w, string(bytes)); err != nil {
 		s.l.Error(err)
 	}
+}
 
-	return
+// parseWait reads "wait" query parameter or WaitHeader. Value is either boolean or timeout of waiting,
+// e.g. "true" waits for default timeout and "30s" waits for 30 seconds.
+func (s itemHandler) parseWait(r *http.Request) (bool, time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		value = r.Header.Get(WaitHeader)
+	}
+
+	if value == "" {
+		return false, 0, nil
+	}
+
+	if wait, err := strconv.ParseBool(value); err == nil {
+		return wait, s.waitTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return false, 0, errors.Errorf("invalid wait value %q", value)
+	}
+
+	return true, timeout, nil
 }
 
 // Download replies with a stream mapped to the chunks of an item.
 // Allows to start download immediately, without waiting chunks to be taken from file servers.
+// Pending items are served from the upload kept on this server.
+// Conditional and range requests are supported with Last-Modified and ETag.
+// Content is served as attachment, "disposition=inline" query parameter lets browsers display it.
+// "rate" query parameter limits download rate, bytes per second, within container limit.
+// Latest version is downloaded, unless "version" query parameter specifies another one.
 func (s itemHandler) Download(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	contentMapper, origFileName, err := s.itemUsecase.Download(r.Context(), params.ByName("id"))
+	if !s.verifyDownload(w, r, params.ByName("id")) {
+		return
+	}
+
+	item, err := s.itemUsecase.Resolve(r.Context(), params.ByName("id"), r.URL.Query().Get("version"))
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	s.serveDownload(w, r, item.ID)
+}
+
+// DownloadHead replies with headers of item download, without opening chunks on file servers.
+func (s itemHandler) DownloadHead(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if !s.verifyDownload(w, r, params.ByName("id")) {
+		return
+	}
+
+	item, err := s.itemUsecase.Resolve(r.Context(), params.ByName("id"), r.URL.Query().Get("version"))
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	s.serveHead(w, r, item.ID)
+}
+
+// serveDownload replies with content of the item, taking disposition and rate from query parameters.
+func (s itemHandler) serveDownload(w http.ResponseWriter, r *http.Request, id string) {
+	disposition, err := parseDisposition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rate, err := parseRate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentMapper, content, err := s.itemUsecase.Download(r.Context(), id, rate)
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+	defer func() {
+		if err := contentMapper.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	setContentHeaders(w, content, disposition)
+	http.ServeContent(w, r, content.Name, content.Modified, contentMapper)
+}
+
+// serveHead replies with headers serveDownload would reply with.
+func (s itemHandler) serveHead(w http.ResponseWriter, r *http.Request, id string) {
+	disposition, err := parseDisposition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content, err := s.itemUsecase.Stat(r.Context(), id)
+	if err != nil {
+		s.replyItemError(w, err)
+		return
+	}
+
+	setContentHeaders(w, content, disposition)
+	http.ServeContent(w, r, content.Name, content.Modified, &headContent{size: content.Size})
+}
+
+// verifyDownload verifies signature of presigned download. Replies with error and reports false if it's invalid.
+// Requests without signature are passed as is.
+func (s itemHandler) verifyDownload(w http.ResponseWriter, r *http.Request, id string) bool {
+	if !presign_usecase.IsSigned(r.URL.Query()) {
+		return true
+	}
+
+	if err := s.presignUsecase.VerifyDownload(id, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// parseMetaHeaders reads item metadata from headers with MetaHeaderPrefix. Keys are lowercased.
+func parseMetaHeaders(header http.Header) item_model.Metadata {
+	res := make(item_model.Metadata)
+
+	for k, v := range header {
+		if strings.HasPrefix(k, MetaHeaderPrefix) && len(v) > 0 {
+			res[strings.ToLower(strings.TrimPrefix(k, MetaHeaderPrefix))] = v[0]
+		}
+	}
+
+	return res
+}
+
+// parseMetaValues reads item metadata from form fields or query parameters with MetaFieldPrefix. Keys are lowercased.
+func parseMetaValues(values map[string][]string) (item_model.Metadata, error) {
+	res := make(item_model.Metadata)
+
+	for k, v := range values {
+		if !strings.HasPrefix(k, MetaFieldPrefix) {
+			continue
+		}
+
+		if len(v) != 1 {
+			return nil, errors.Errorf("metadata %q is specified more than once", k)
+		}
+
+		res[strings.ToLower(strings.TrimPrefix(k, MetaFieldPrefix))] = v[0]
+	}
+
+	return res, nil
+}
+
+// parseDisposition reads "disposition" query parameter, either "attachment" (default) or "inline".
+func parseDisposition(r *http.Request) (string, error) {
+	switch value := r.URL.Query().Get("disposition"); value {
+	case "", "attachment":
+		return "attachment", nil
+	case "inline":
+		return value, nil
+	default:
+		return "", errors.Errorf("invalid disposition %q", value)
+	}
+}
+
+// parseRate reads "rate" query parameter, bytes per second. Zero is returned if it's not set.
+func parseRate(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get("rate")
+	if value == "" {
+		return 0, nil
+	}
+
+	res, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || res <= 0 {
+		return 0, errors.Errorf("invalid rate %q", value)
+	}
+
+	return res, nil
+}
+
+// inlineTypes are media types served inline when requested. Types are declared by uploaders, so types able to run
+// scripts on the gateway origin, e.g. HTML or SVG, are always served as attachments.
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/avif":      true,
+	"image/bmp":       true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// inlineAllowed reports whether content of the media type can be served inline.
+func inlineAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return inlineTypes[mediaType] || strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/")
+}
+
+// setContentHeaders sets headers describing item content. Strong ETag is made of content hash,
+// so conditional requests are handled by http.ServeContent.
+func setContentHeaders(w http.ResponseWriter, content item_usecase.Content, disposition string) {
+	contentType := content.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if disposition == "inline" && !inlineAllowed(contentType) {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Disposition", disposition+"; filename="+strconv.Quote(content.Name))
+	w.Header().Set("Content-Type", contentType)
+	// Browsers must not guess another type of inline content.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Inline content is isolated from the gateway origin anyway.
+	if disposition == "inline" {
+		w.Header().Set("Content-Security-Policy", "sandbox")
+	}
+
+	for k, v := range content.Metadata {
+		w.Header().Set(MetaHeaderPrefix+k, v)
+	}
+
+	if content.Hash != "" {
+		w.Header().Set("ETag", strconv.Quote(content.Hash))
+	}
+
+	if content.CacheControl != "" {
+		w.Header().Set("Cache-Control", content.CacheControl)
+	}
+}
+
+// headContent stands for item content on HEAD requests. Only its size is known, content is never read.
+type headContent struct {
+	size   int64
+	offset int64
+}
+
+func (s *headContent) Read([]byte) (int, error) {
+	return 0, errors.New("content isn't available on HEAD request")
+}
+
+func (s *headContent) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return s.offset, errors.Errorf("unknown whence %d", whence)
+	}
+
+	s.offset = offset
+	return s.offset, nil
+}
+
+// Progress replies with transfer progress of an item.
+func (s itemHandler) Progress(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	progress, err := s.itemUsecase.Progress(r.Context(), params.ByName("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(progress)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(origFileName))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	http.ServeContent(w, r, origFileName, time.Time{}, contentMapper)
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Events streams transfer progress of an item as Server-Sent Events until the item reaches its final status.
+func (s itemHandler) Events(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	progress, err := s.itemUsecase.WatchProgress(r.Context(), params.ByName("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for p := range progress {
+		bytes, err := json.Marshal(p)
+		if err != nil {
+			s.l.Error(err)
+			return
+		}
+
+		if _, err = fmt.Fprintf(w, "event: progress\ndata: %s\n\n", bytes); err != nil {
+			s.l.Error(err)
+			return
+		}
+		flusher.Flush()
+	}
 }
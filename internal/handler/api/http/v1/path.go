@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"encoding/json"
+	"github.com/PavelKhripkov/object_storage/internal/domain/model/item_model"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/item_usecase"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/path_usecase"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"time"
+)
+
+type pathHandler struct {
+	pathUsecase *path_usecase.Usecase
+	// items serves downloads and uploads of items found by path.
+	items itemHandler
+	l     *log.Entry
+}
+
+// NewPathHandler creates handler addressing containers and items by paths. Uploads waiting for durability
+// are limited by waitTimeout by default.
+func NewPathHandler(pathUsecase *path_usecase.Usecase, itemUsecase *item_usecase.Usecase, waitTimeout time.Duration, l *log.Logger) Handler {
+	return &pathHandler{
+		pathUsecase: pathUsecase,
+		items: itemHandler{
+			itemUsecase: itemUsecase,
+			waitTimeout: waitTimeout,
+			l:           l.WithField("component", "ItemHandler"),
+		},
+		l: l.WithField("component", "PathHandler"),
+	}
+}
+
+func (s pathHandler) Register(router *httprouter.Router) {
+	router.GET("/fs/*path", s.Get)
+	router.HEAD("/fs/*path", s.Head)
+	router.PUT("/fs/*path", s.Put)
+}
+
+// Get replies with item content found by path, the same way as item download. Container found by path is replied
+// as entity.
+func (s pathHandler) Get(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	entry, ok := s.resolve(w, r, params.ByName("path"))
+	if !ok {
+		return
+	}
+
+	if entry.Item != nil {
+		s.items.serveDownload(w, r, entry.Item.ID)
+		return
+	}
+
+	bytes, err := json.Marshal(entry.Container)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// Head replies with headers of item download found by path.
+func (s pathHandler) Head(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	entry, ok := s.resolve(w, r, params.ByName("path"))
+	if !ok {
+		return
+	}
+
+	if entry.Item != nil {
+		s.items.serveHead(w, r, entry.Item.ID)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+}
+
+// Put stores request body as item by path, overwriting item with the same path once it's stored.
+// "parents=true" query parameter creates missing containers. Waiting is requested the same way as for item upload.
+func (s pathHandler) Put(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxFileSize)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	wait, waitTimeout, err := s.items.parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	parents, err := parseQueryBool(r, "parents")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.pathUsecase.Put(r.Context(), path_usecase.PutItemDTO{
+		Path:        params.ByName("path"),
+		Body:        r.Body,
+		ContentType: r.Header.Get("Content-Type"),
+		Metadata:    parseMetaHeaders(r.Header),
+		Parents:     parents,
+	})
+
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, path_usecase.ErrInvalidPath), errors.Is(err, item_model.ErrInvalidMetadata):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, path_usecase.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.items.replyStored(w, r, item, wait, waitTimeout)
+}
+
+// resolve finds entity by path. Replies with error and reports false if it's not found.
+func (s pathHandler) resolve(w http.ResponseWriter, r *http.Request, p string) (path_usecase.Entry, bool) {
+	entry, err := s.pathUsecase.Resolve(r.Context(), p, r.URL.Query().Get("version"))
+
+	switch {
+	case errors.Is(err, path_usecase.ErrInvalidPath), errors.Is(err, item_usecase.ErrInvalidVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return path_usecase.Entry{}, false
+	case errors.Is(err, path_usecase.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return path_usecase.Entry{}, false
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return path_usecase.Entry{}, false
+	}
+
+	return entry, true
+}
@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"encoding/json"
+	"github.com/PavelKhripkov/object_storage/internal/domain/service/presign_service"
+	"github.com/PavelKhripkov/object_storage/internal/domain/usecase/presign_usecase"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+type presignHandler struct {
+	presignUsecase *presign_usecase.Usecase
+	l              *log.Entry
+}
+
+func NewPresignHandler(presignUsecase *presign_usecase.Usecase, l *log.Logger) Handler {
+	return &presignHandler{
+		presignUsecase: presignUsecase,
+		l:              l.WithField("component", "PresignHandler"),
+	}
+}
+
+func (s presignHandler) Register(router *httprouter.Router) {
+	router.POST("/presign", s.Presign)
+}
+
+// Presign issues signed URL for item download or for upload into a container.
+func (s presignHandler) Presign(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	decoder := json.NewDecoder(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.l.Error(err)
+		}
+	}()
+
+	var dto presign_usecase.PresignDTO
+
+	if err := decoder.Decode(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.presignUsecase.Presign(r.Context(), dto)
+
+	switch {
+	case errors.Is(err, presign_service.ErrDisabled), errors.Is(err, presign_usecase.ErrItemNotFound),
+		errors.Is(err, presign_usecase.ErrContainerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, presign_usecase.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		s.l.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = io.WriteString(w, string(bytes)); err != nil {
+		s.l.Error(err)
+	}
+}
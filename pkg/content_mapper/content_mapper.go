@@ -1,50 +1,139 @@
 package content_mapper
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"sync"
+	"time"
 )
 
 // Part represents one part of a stream to be read.
 // TODO need constructor?
 type Part struct {
 	Start, End int64
-	Open       func() (io.ReadSeekCloser, error)
-	close      func() error
-	file       io.ReadSeeker
+	// Sources open the part, e.g. replicas of a chunk. The first one is used while it works,
+	// the next ones are used if it fails.
+	Sources []func() (io.ReadSeekCloser, error)
+}
+
+// ReadAhead configures prefetching of parts.
+type ReadAhead struct {
+	// Parts is a number of parts following the current one, which are opened and buffered in parallel.
+	// Zero disables prefetching, parts are read directly one by one then.
+	Parts int
+	// BufferSize limits number of bytes buffered for every part being fetched.
+	BufferSize int
+}
+
+// Retry configures reconnection to a source of the part after failure.
+type Retry struct {
+	// Retries is a number of times a failed source is reopened before switching to the next one.
+	Retries int
+	// Delay is a delay before the first retry, every next one waits longer.
+	Delay time.Duration
+}
+
+// Option configures ContentMapper.
+type Option func(*ContentMapper)
+
+// WithReadAhead enables prefetching of parts.
+func WithReadAhead(readAhead ReadAhead) Option {
+	return func(s *ContentMapper) {
+		s.readAhead = readAhead
+	}
+}
+
+// WithRetry enables reconnection to failed sources.
+func WithRetry(retry Retry) Option {
+	return func(s *ContentMapper) {
+		s.retry = retry
+	}
+}
+
+// WithContext stops reading once ctx is done, e.g. when client of a download is gone.
+// Reads waiting to retry are aborted, the mapper still must be closed.
+func WithContext(ctx context.Context) Option {
+	return func(s *ContentMapper) {
+		s.ctx = ctx
+	}
 }
 
 // ContentMapper maps reading of a single source into multiple parts.
-// Handles Open, Close, Seek, Read methods of Parts.
+// Handles Open, Close, Seek, Read methods of Parts. Read and Seek share a single offset,
+// ReadAt reads independently of them and can be called concurrently.
 type ContentMapper struct {
 	io.ReadSeeker
-	parts      []*Part
-	size       int64
-	currentPos int
-	l          *logrus.Entry
+	parts     []*Part
+	size      int64
+	readAhead ReadAhead
+	retry     Retry
+	ctx       context.Context
+	l         *logrus.Entry
 
 	mu     sync.Mutex
 	offset int64
+
+	// Currently opened part, when parts are read directly.
+	file     *partReader
+	filePart int
+	filePos  int64
+
+	// Parts being fetched, the first one is the current.
+	fetches []*fetch
+
+	// Idle readers of ReadAt.
+	pool readerPool
+	// done is closed on Close, so reads waiting to retry are aborted. It's closed before taking mu,
+	// since Read holds mu while waiting.
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewContentMapper create new content mapper with provided parts.
-func NewContentMapper(l *logrus.Entry, parts []*Part, size int64) (*ContentMapper, error) {
+func NewContentMapper(l *logrus.Entry, parts []*Part, size int64, opts ...Option) (*ContentMapper, error) {
 	res := &ContentMapper{
 		l:     l,
 		parts: parts,
 		size:  size,
+		pool:  readerPool{idle: make(map[int][]*partReader)},
+		done:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(res)
 	}
 
 	if err := res.validate(); err != nil {
 		return nil, err
 	}
 
+	if res.ctx != nil {
+		go res.watch()
+	}
+
 	return res, nil
 }
 
+// watch stops reading once context is done, until the mapper is closed.
+func (s *ContentMapper) watch() {
+	select {
+	case <-s.ctx.Done():
+		s.stop()
+	case <-s.done:
+	}
+}
+
+// stop aborts reads waiting to retry or for fetched data.
+func (s *ContentMapper) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Seek sets offset of the next Read. Prefetched data is dropped if offset is changed.
 func (s *ContentMapper) Seek(offset int64, whence int) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -63,100 +152,279 @@ func (s *ContentMapper) Seek(offset int64, whence int) (int64, error) {
 		return s.offset, os.ErrInvalid
 	}
 
+	if offset != s.offset {
+		s.stopFetches()
+	}
+
 	s.offset = offset
 	return s.offset, nil
 }
 
+// Read reads from the part containing current offset. Reading doesn't cross part boundary.
 func (s *ContentMapper) Read(b []byte) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var read int
-	var err error
-	curr := s.parts[s.currentPos].file
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
 
-	if curr == nil {
-		curr, err = s.open()
-		if err != nil {
-			return 0, err
-		}
+	if len(b) == 0 {
+		return 0, nil
 	}
 
-	for read < len(b) {
-		rb := b[read:]
-		n, err := curr.Read(rb)
-		read += n
-		s.offset += int64(n)
+	idx := s.partAt(s.offset)
+	if idx < 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
 
-		if err == io.EOF {
-			curr, err = s.next()
-			if err != nil {
-				return read, err
-			}
-		} else if err != nil {
-			return read, err
-		}
+	if s.readAhead.Parts > 0 {
+		return s.readFetched(b, idx)
 	}
 
-	return read, nil
+	return s.readDirect(b, idx)
 }
 
-func (s *ContentMapper) open() (io.ReadSeeker, error) {
-	for i, part := range s.parts {
-		if s.offset >= part.Start && s.offset <= part.End {
-			file, err := part.Open()
-			if err != nil {
-				return nil, err
-			}
+// Close closes opened parts and stops fetching. Reads in progress are aborted if they wait to retry.
+func (s *ContentMapper) Close() error {
+	// Read in progress is stopped first, so mu is released.
+	s.stop()
 
-			part.close = func() error {
-				return file.Close()
-			}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-			_, err = file.Seek(s.offset-part.Start, io.SeekStart)
-			if err != nil {
-				return nil, err
-			}
+	s.stopFetches()
+	s.closePool()
+
+	return s.closeFile()
+}
 
-			s.currentPos = i
-			s.parts[s.currentPos].file = file
+// readDirect reads from the part, opening it if needed.
+func (s *ContentMapper) readDirect(b []byte, idx int) (int, error) {
+	part := s.parts[idx]
 
-			return file, nil
+	if s.file == nil || s.filePart != idx || s.filePos != s.offset {
+		if err := s.closeFile(); err != nil {
+			s.l.Error(err)
 		}
+
+		s.file = newPartReader(part, s.offset-part.Start, s.retry, s.done, s.l)
+		s.filePart = idx
+		s.filePos = s.offset
+	}
+
+	n, err := s.file.Read(b)
+	s.offset += int64(n)
+	s.filePos += int64(n)
+
+	if s.offset > part.End {
+		return n, s.closeFile()
+	}
+
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
 	}
-	return nil, io.ErrUnexpectedEOF
+
+	return n, err
 }
 
-func (s *ContentMapper) next() (io.ReadSeeker, error) {
-	if s.parts[s.currentPos].close != nil {
-		s.parts[s.currentPos].file = nil
-		err := s.parts[s.currentPos].close()
-		if err != nil {
-			return nil, err
+func (s *ContentMapper) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+
+	file := s.file
+	s.file = nil
+
+	return file.Close()
+}
+
+// readFetched reads from the fetch of the part, starting fetches of the part and following ones if needed.
+func (s *ContentMapper) readFetched(b []byte, idx int) (int, error) {
+	if len(s.fetches) > 0 && (s.fetches[0].part != idx || s.fetches[0].pos != s.offset) {
+		s.stopFetches()
+	}
+
+	if len(s.fetches) == 0 {
+		s.fetches = append(s.fetches, s.startFetch(idx, s.offset))
+	}
+
+	for next := s.fetches[len(s.fetches)-1].part + 1; next < len(s.parts) && next <= idx+s.readAhead.Parts; next++ {
+		s.fetches = append(s.fetches, s.startFetch(next, s.parts[next].Start))
+	}
+
+	current := s.fetches[0]
+
+	n, err := current.read(b)
+	s.offset += int64(n)
+
+	if current.pos > s.parts[idx].End {
+		current.stop()
+		s.fetches = s.fetches[1:]
+	}
+
+	return n, err
+}
+
+// startFetch starts fetching the part from the offset.
+func (s *ContentMapper) startFetch(idx int, offset int64) *fetch {
+	part := s.parts[idx]
+
+	blocks := s.readAhead.BufferSize / fetchBlockSize
+	if blocks < 1 {
+		blocks = 1
+	}
+
+	res := &fetch{
+		part:   idx,
+		pos:    offset,
+		blocks: make(chan []byte, blocks),
+		free:   make(chan []byte, blocks+1),
+		done:   make(chan struct{}),
+		closed: s.done,
+	}
+
+	go res.run(newPartReader(part, offset-part.Start, s.retry, res.done, s.l), part.End-offset+1)
+
+	return res
+}
+
+func (s *ContentMapper) stopFetches() {
+	for _, f := range s.fetches {
+		f.stop()
+	}
+
+	s.fetches = nil
+}
+
+// partAt returns index of the part containing offset, -1 if there is no such part.
+func (s *ContentMapper) partAt(offset int64) int {
+	for i, part := range s.parts {
+		if offset >= part.Start && offset <= part.End {
+			return i
 		}
 	}
 
-	if s.currentPos < len(s.parts)-1 {
-		s.currentPos++
+	return -1
+}
+
+func (s *ContentMapper) validate() error {
+	// TODO validate
+	return nil
+}
+
+// fetchBlockSize is a size of blocks fetched parts are buffered with.
+const fetchBlockSize = 256 * 1024
+
+// fetch reads a part in background, buffering limited number of blocks.
+type fetch struct {
+	part int
+	// pos is an offset of the next byte to be read from the fetch.
+	pos int64
+
+	blocks chan []byte
+	free   chan []byte
+	done   chan struct{}
+	// closed is done channel of the mapper, reading is aborted once it's closed.
+	closed <-chan struct{}
+	// err is set before blocks is closed.
+	err error
+
+	current []byte
+	block   []byte
+}
+
+// run reads size bytes of the part into blocks.
+func (s *fetch) run(file *partReader, size int64) {
+	defer close(s.blocks)
+	defer file.closeFile()
+
+	for size > 0 {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		var block []byte
+
+		select {
+		case block = <-s.free:
+		default:
+			block = make([]byte, fetchBlockSize)
+		}
+
+		if size < int64(len(block)) {
+			block = block[:size]
+		}
+
+		n, err := io.ReadFull(file, block)
+		if n > 0 {
+			select {
+			case s.blocks <- block[:n]:
+			case <-s.done:
+				return
+			}
+		}
+
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 
-		file, err := s.parts[s.currentPos].Open()
 		if err != nil {
-			return nil, err
+			s.err = err
+			return
 		}
 
-		s.parts[s.currentPos].file = file
+		size -= int64(n)
+	}
+}
+
+// read reads buffered data, waiting for the next block if needed.
+func (s *fetch) read(b []byte) (int, error) {
+	if len(s.current) == 0 {
+		if s.block != nil {
+			select {
+			case s.free <- s.block[:cap(s.block)]:
+			default:
+			}
+			s.block = nil
+		}
+
+		var (
+			block []byte
+			ok    bool
+		)
 
-		s.parts[s.currentPos].close = func() error {
-			return file.Close()
+		select {
+		case block, ok = <-s.blocks:
+		case <-s.closed:
+			return 0, errStopped
 		}
 
-		return file, nil
+		if !ok {
+			if s.err != nil {
+				return 0, s.err
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		s.block = block
+		s.current = block
 	}
 
-	return nil, io.EOF
+	n := copy(b, s.current)
+	s.current = s.current[n:]
+	s.pos += int64(n)
+
+	return n, nil
 }
 
-func (s *ContentMapper) validate() error {
-	// TODO validate
-	return nil
+// stop aborts fetching. Opened part is closed in background.
+func (s *fetch) stop() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
 }
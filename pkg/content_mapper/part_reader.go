@@ -0,0 +1,177 @@
+package content_mapper
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"time"
+)
+
+// errStopped is returned when reading of a part is aborted while waiting to be retried.
+var errStopped = errors.New("reading of part is stopped")
+
+// partReader reads a part from its sources. On failure the part is reopened at the current offset:
+// the same source is retried first, then the next one is used.
+type partReader struct {
+	part  *Part
+	retry Retry
+	done  <-chan struct{}
+	l     *logrus.Entry
+
+	// pos is an offset within the part.
+	pos  int64
+	file io.ReadSeekCloser
+
+	source   int
+	failures int
+}
+
+func newPartReader(part *Part, pos int64, retry Retry, done <-chan struct{}, l *logrus.Entry) *partReader {
+	return &partReader{
+		part:  part,
+		retry: retry,
+		done:  done,
+		l:     l,
+		pos:   pos,
+	}
+}
+
+// Read reads from the part, limited by the part end. Data read before a failure is returned first,
+// the failed source is reopened on the next Read.
+func (s *partReader) Read(p []byte) (int, error) {
+	remaining := s.part.End - s.part.Start + 1 - s.pos
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	for {
+		if s.file == nil {
+			if err := s.open(); err != nil {
+				if err = s.fail(err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+
+		n, err := s.file.Read(p)
+		s.pos += int64(n)
+
+		if n > 0 {
+			s.failures = 0
+		}
+
+		// Source ended before the part does.
+		if err == io.EOF && s.pos < s.part.End-s.part.Start+1 {
+			if n > 0 {
+				err = nil
+			} else {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		s.closeFile()
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if err = s.fail(err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Close closes currently opened source.
+func (s *partReader) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	file := s.file
+	s.file = nil
+
+	return file.Close()
+}
+
+// seek moves to the position within the part. Opened source is seeked, or reopened on the next Read if seeking fails.
+func (s *partReader) seek(pos int64) {
+	if pos == s.pos {
+		return
+	}
+
+	s.pos = pos
+
+	if s.file == nil {
+		return
+	}
+
+	if _, err := s.file.Seek(pos, io.SeekStart); err != nil {
+		s.l.WithError(err).Warn("Seeking part failed, it's reopened.")
+		s.closeFile()
+	}
+}
+
+// open opens current source at current offset.
+func (s *partReader) open() error {
+	if s.source >= len(s.part.Sources) {
+		return errors.New("part has no sources")
+	}
+
+	file, err := s.part.Sources[s.source]()
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Seek(s.pos, io.SeekStart); err != nil {
+		if err := file.Close(); err != nil {
+			s.l.Error(err)
+		}
+		return err
+	}
+
+	s.file = file
+
+	return nil
+}
+
+// fail registers failure of current source and waits before it's retried.
+// Switches to the next source once retries are exhausted. Returns error if there are no sources left.
+func (s *partReader) fail(err error) error {
+	s.failures++
+
+	if s.failures > s.retry.Retries {
+		s.source++
+		s.failures = 0
+
+		if s.source >= len(s.part.Sources) {
+			return err
+		}
+
+		s.l.WithError(err).Warnf("Reading part at %d failed, switching to source %d.", s.part.Start, s.source)
+		return nil
+	}
+
+	s.l.WithError(err).Warnf("Reading part at %d failed, retry %d of %d.", s.part.Start, s.failures, s.retry.Retries)
+
+	select {
+	case <-time.After(s.retry.Delay * time.Duration(s.failures)):
+		return nil
+	case <-s.done:
+		return errStopped
+	}
+}
+
+func (s *partReader) closeFile() {
+	if err := s.Close(); err != nil {
+		s.l.Error(err)
+	}
+}
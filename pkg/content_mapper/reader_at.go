@@ -0,0 +1,192 @@
+package content_mapper
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// maxIdleReaders limits number of idle readers kept for every part to be reused by ReadAt.
+const maxIdleReaders = 4
+
+// readerPool keeps idle part readers, so sequential ReadAt calls don't reopen parts.
+type readerPool struct {
+	mu     sync.Mutex
+	idle   map[int][]*partReader
+	closed bool
+}
+
+// segment is a range of ReadAt buffer, which is read from a single part.
+type segment struct {
+	part int
+	pos  int64
+	buf  []byte
+
+	n   int
+	err error
+}
+
+// ReadAt reads len(b) bytes at the offset. It doesn't use the offset of Read and Seek and is safe for concurrent use.
+// Range covering several parts is read from them in parallel. Readers of parts are reused by following calls.
+func (s *ContentMapper) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	s.pool.mu.Lock()
+	closed := s.pool.closed
+	s.pool.mu.Unlock()
+
+	if closed {
+		return 0, os.ErrClosed
+	}
+
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(b))
+
+	var eof bool
+
+	if end > s.size {
+		end = s.size
+		eof = true
+	}
+
+	var segments []*segment
+
+	for pos := off; pos < end; {
+		idx := s.partAt(pos)
+		if idx < 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		segEnd := s.parts[idx].End + 1
+		if segEnd > end {
+			segEnd = end
+		}
+
+		segments = append(segments, &segment{
+			part: idx,
+			pos:  pos,
+			buf:  b[pos-off : segEnd-off],
+		})
+
+		pos = segEnd
+	}
+
+	if len(segments) == 1 {
+		s.readSegment(segments[0])
+	} else {
+		var wg sync.WaitGroup
+
+		for _, seg := range segments {
+			wg.Add(1)
+
+			go func(seg *segment) {
+				defer wg.Done()
+				s.readSegment(seg)
+			}(seg)
+		}
+
+		wg.Wait()
+	}
+
+	// Bytes are reported up to the first failed segment only.
+	var n int
+
+	for _, seg := range segments {
+		n += seg.n
+
+		if seg.err != nil {
+			return n, seg.err
+		}
+	}
+
+	if eof {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// readSegment fills segment buffer from its part.
+func (s *ContentMapper) readSegment(seg *segment) {
+	part := s.parts[seg.part]
+	r := s.getReader(seg.part, seg.pos-part.Start)
+
+	seg.n, seg.err = io.ReadFull(r, seg.buf)
+	if seg.err == io.EOF {
+		seg.err = io.ErrUnexpectedEOF
+	}
+
+	if seg.err != nil {
+		if err := r.Close(); err != nil {
+			s.l.Error(err)
+		}
+		return
+	}
+
+	s.putReader(seg.part, r)
+}
+
+// getReader returns reader of the part at the position, preferring idle one which is already there.
+func (s *ContentMapper) getReader(idx int, pos int64) *partReader {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+
+	idle := s.pool.idle[idx]
+	if len(idle) == 0 {
+		return newPartReader(s.parts[idx], pos, s.retry, s.done, s.l)
+	}
+
+	pick := len(idle) - 1
+	for i, r := range idle {
+		if r.pos == pos {
+			pick = i
+			break
+		}
+	}
+
+	res := idle[pick]
+	s.pool.idle[idx] = append(idle[:pick], idle[pick+1:]...)
+
+	res.seek(pos)
+
+	return res
+}
+
+// putReader returns reader to the pool. Reader is closed if the pool is full or closed.
+func (s *ContentMapper) putReader(idx int, r *partReader) {
+	s.pool.mu.Lock()
+
+	if !s.pool.closed && len(s.pool.idle[idx]) < maxIdleReaders {
+		s.pool.idle[idx] = append(s.pool.idle[idx], r)
+		s.pool.mu.Unlock()
+		return
+	}
+
+	s.pool.mu.Unlock()
+
+	if err := r.Close(); err != nil {
+		s.l.Error(err)
+	}
+}
+
+// closePool closes idle readers. Readers in use are closed once they're returned.
+func (s *ContentMapper) closePool() {
+	s.pool.mu.Lock()
+	idle := s.pool.idle
+	s.pool.idle = nil
+	s.pool.closed = true
+	s.pool.mu.Unlock()
+
+	for _, readers := range idle {
+		for _, r := range readers {
+			if err := r.Close(); err != nil {
+				s.l.Error(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package disk_cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tempPrefix = "tmp-"
+
+// Stats represents cache usage.
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Size      int64 `json:"size"`
+	MaxSize   int64 `json:"max_size"`
+}
+
+// Cache is an on-disk LRU cache of files. Files are added while they're being read from the source,
+// so nothing is fetched just to be cached. Total size of cached files is limited.
+type Cache struct {
+	dir     string
+	maxSize int64
+	l       *logrus.Entry
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+
+	hits, misses, evictions int64
+}
+
+// entry represents a cached file.
+type entry struct {
+	name string
+	size int64
+}
+
+// NewCache creates cache in the directory. Files cached before are kept, least recently used first to be evicted.
+func NewCache(dir string, maxSize int64, l *logrus.Entry) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	res := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		l:       l,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	if err := res.load(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Open opens cached file of the key. Reports false if the key isn't cached.
+func (s *Cache) Open(key string) (*os.File, bool) {
+	name := fileName(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[name]
+	if !ok {
+		s.misses++
+		return nil, false
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		s.l.Error(err)
+		s.remove(el)
+		s.misses++
+		return nil, false
+	}
+
+	s.hits++
+	s.lru.MoveToFront(el)
+
+	// Modification time keeps recency of use between restarts.
+	now := time.Now()
+	if err = os.Chtimes(f.Name(), now, now); err != nil {
+		s.l.Error(err)
+	}
+
+	return f, true
+}
+
+// Populate wraps the source of the key, so the source is cached once it's read completely, in any order.
+// Sources larger than the cache are returned as is.
+func (s *Cache) Populate(key string, src io.ReadSeekCloser) io.ReadSeekCloser {
+	size, err := src.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = src.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		s.l.Error(err)
+		return src
+	}
+
+	if size > s.maxSize {
+		return src
+	}
+
+	tmp, err := os.CreateTemp(s.dir, tempPrefix)
+	if err != nil {
+		s.l.Error(err)
+		return src
+	}
+
+	return &populator{
+		cache: s,
+		name:  fileName(key),
+		src:   src,
+		tmp:   tmp,
+		size:  size,
+	}
+}
+
+// Stats returns cache usage.
+func (s *Cache) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Stats{
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+		Entries:   s.lru.Len(),
+		Size:      s.size,
+		MaxSize:   s.maxSize,
+	}
+}
+
+// commit moves completely read file into the cache, evicting least recently used files to fit it.
+func (s *Cache) commit(name string, tmpPath string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[name]; ok {
+		return os.Remove(tmpPath)
+	}
+
+	for s.size+size > s.maxSize && s.lru.Len() > 0 {
+		s.remove(s.lru.Back())
+		s.evictions++
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, name)); err != nil {
+		return err
+	}
+
+	s.entries[name] = s.lru.PushFront(&entry{name: name, size: size})
+	s.size += size
+
+	return nil
+}
+
+// remove removes file from the cache. Must be called under lock.
+func (s *Cache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+
+	s.lru.Remove(el)
+	delete(s.entries, e.name)
+	s.size -= e.size
+
+	// Files being read stay available to their readers until closed.
+	if err := os.Remove(filepath.Join(s.dir, e.name)); err != nil && !os.IsNotExist(err) {
+		s.l.Error(err)
+	}
+}
+
+// load indexes files cached before and removes leftovers of unfinished populations.
+func (s *Cache) load() error {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var infos []os.FileInfo
+
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(s.dir, de.Name())
+
+		if strings.HasPrefix(de.Name(), tempPrefix) {
+			if err := os.Remove(path); err != nil {
+				s.l.Error(err)
+			}
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+
+	for _, info := range infos {
+		s.entries[info.Name()] = s.lru.PushBack(&entry{name: info.Name(), size: info.Size()})
+		s.size += info.Size()
+	}
+
+	for s.size > s.maxSize && s.lru.Len() > 0 {
+		s.remove(s.lru.Back())
+	}
+
+	return nil
+}
+
+// fileName returns name of the cached file of the key. Keys are hashed, so they're safe to be used as file names.
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// populator copies data read from the source into temporary file.
+// Once every byte of the source has been read, the file is moved into the cache on Close.
+type populator struct {
+	cache *Cache
+	name  string
+	src   io.ReadSeekCloser
+	tmp   *os.File
+	size  int64
+
+	pos    int64
+	failed bool
+	// covered holds sorted non-overlapping ranges of the source copied to the file.
+	covered []span
+}
+
+// span represents range [start, end) of the source.
+type span struct {
+	start, end int64
+}
+
+func (s *populator) Read(p []byte) (int, error) {
+	n, err := s.src.Read(p)
+
+	if n > 0 && !s.failed {
+		if _, werr := s.tmp.WriteAt(p[:n], s.pos); werr != nil {
+			s.cache.l.Error(werr)
+			s.failed = true
+		} else {
+			s.cover(s.pos, s.pos+int64(n))
+		}
+	}
+
+	s.pos += int64(n)
+
+	return n, err
+}
+
+func (s *populator) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.src.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+
+	s.pos = pos
+	return pos, nil
+}
+
+func (s *populator) Close() error {
+	err := s.src.Close()
+
+	complete := !s.failed && len(s.covered) == 1 && s.covered[0] == span{0, s.size}
+	if s.size == 0 {
+		complete = !s.failed
+	}
+
+	if cerr := s.tmp.Close(); cerr != nil {
+		s.cache.l.Error(cerr)
+		complete = false
+	}
+
+	if complete {
+		if cerr := s.cache.commit(s.name, s.tmp.Name(), s.size); cerr != nil {
+			s.cache.l.Error(cerr)
+			complete = false
+		}
+	}
+
+	if !complete {
+		if rerr := os.Remove(s.tmp.Name()); rerr != nil {
+			s.cache.l.Error(rerr)
+		}
+	}
+
+	return err
+}
+
+// cover adds range to covered ones, merging adjacent and overlapping ranges.
+func (s *populator) cover(start, end int64) {
+	res := make([]span, 0, len(s.covered)+1)
+	added := span{start, end}
+
+	for _, c := range s.covered {
+		switch {
+		case c.end < added.start:
+			res = append(res, c)
+		case added.end < c.start:
+			res = append(res, added)
+			added = c
+		default:
+			if c.start < added.start {
+				added.start = c.start
+			}
+			if c.end > added.end {
+				added.end = c.end
+			}
+		}
+	}
+
+	s.covered = append(res, added)
+}
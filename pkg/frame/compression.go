@@ -0,0 +1,90 @@
+package frame
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sync"
+)
+
+// gzipCodec compresses every frame into a separate gzip stream.
+type gzipCodec struct{}
+
+// NewGzipCodec creates codec compressing frames with gzip.
+func NewGzipCodec() Codec {
+	return gzipCodec{}
+}
+
+func (s gzipCodec) Encode(dst, src []byte, _ int64) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s gzipCodec) Decode(dst, src []byte, _ int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(dst)
+	if _, err = io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+
+	if err = r.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec compresses every frame into a separate zstd frame.
+// Encoder and decoder are shared, since EncodeAll and DecodeAll are safe for concurrent use.
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewZstdCodec creates codec compressing frames with zstd.
+func NewZstdCodec() (Codec, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+
+	return zstdCodec{encoder: zstdEncoder, decoder: zstdDecoder}, nil
+}
+
+func (s zstdCodec) Encode(dst, src []byte, _ int64) ([]byte, error) {
+	return s.encoder.EncodeAll(src, dst), nil
+}
+
+func (s zstdCodec) Decode(dst, src []byte, _ int64) ([]byte, error) {
+	return s.decoder.DecodeAll(src, dst)
+}
@@ -0,0 +1,80 @@
+package frame
+
+import (
+	"io"
+)
+
+// Encoder reads raw data from source and produces framed stream of encoded data.
+type Encoder struct {
+	src       io.Reader
+	codec     Codec
+	frameSize int
+
+	raw     []byte
+	out     []byte
+	pending []byte
+	lengths []uint32
+	rawSize int64
+	done    bool
+}
+
+// NewEncoder creates new Encoder. Frame size must be positive.
+func NewEncoder(src io.Reader, codec Codec, frameSize int) *Encoder {
+	return &Encoder{
+		src:       src,
+		codec:     codec,
+		frameSize: frameSize,
+		raw:       make([]byte, frameSize),
+	}
+}
+
+// Read reads encoded stream.
+func (s *Encoder) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		if err := s.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+
+	return n, nil
+}
+
+// fill encodes next frame. Appends trailer when source is exhausted.
+func (s *Encoder) fill() error {
+	var last bool
+
+	n, err := io.ReadFull(s.src, s.raw)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	}
+
+	s.pending = nil
+
+	if n > 0 {
+		s.out, err = s.codec.Encode(s.out[:0], s.raw[:n], int64(len(s.lengths)))
+		if err != nil {
+			return err
+		}
+
+		s.lengths = append(s.lengths, uint32(len(s.out)))
+		s.rawSize += int64(n)
+		s.pending = s.out
+	}
+
+	if last {
+		s.pending = append(s.pending, trailer(s.lengths, s.rawSize, s.frameSize)...)
+		s.done = true
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package frame
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"github.com/pkg/errors"
+)
+
+// aeadCodec seals every frame with AEAD cipher.
+type aeadCodec struct {
+	aead   cipher.AEAD
+	prefix []byte
+}
+
+// NewAEADCodec creates codec sealing every frame with AEAD cipher.
+// Nonce is built from prefix followed by 8 bytes of frame index,
+// so prefix must be unique for every stream encrypted with the same key.
+func NewAEADCodec(aead cipher.AEAD, prefix []byte) (Codec, error) {
+	if len(prefix)+8 != aead.NonceSize() {
+		return nil, errors.Errorf("nonce prefix must be %d bytes long", aead.NonceSize()-8)
+	}
+
+	return aeadCodec{aead: aead, prefix: prefix}, nil
+}
+
+func (s aeadCodec) Encode(dst, src []byte, index int64) ([]byte, error) {
+	return s.aead.Seal(dst, s.nonce(index), src, nil), nil
+}
+
+func (s aeadCodec) Decode(dst, src []byte, index int64) ([]byte, error) {
+	return s.aead.Open(dst, s.nonce(index), src, nil)
+}
+
+func (s aeadCodec) nonce(index int64) []byte {
+	res := make([]byte, s.aead.NonceSize())
+	copy(res, s.prefix)
+	binary.BigEndian.PutUint64(res[len(s.prefix):], uint64(index))
+
+	return res
+}
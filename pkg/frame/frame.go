@@ -0,0 +1,82 @@
+package frame
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+)
+
+// DefaultFrameSize is a size of raw data encoded into a single frame.
+const DefaultFrameSize = 1024 * 1024 // 1 Mb
+
+// Framed stream layout:
+// [frame 0]...[frame N-1][N * uint32 encoded frame lengths][uint64 raw size][uint32 frame size][uint32 N][magic]
+// Every frame except the last one holds exactly frame size bytes of raw data,
+// so any raw offset can be mapped to a frame without reading the frames before it.
+const trailerSize = 8 + 4 + 4 + 4
+
+var magic = [4]byte{'O', 'S', 'F', '1'}
+
+var ErrInvalidStream = errors.New("invalid framed stream")
+
+// Codec encodes and decodes payload of a single frame.
+// Both methods append result to dst and return the extended slice.
+// Index is a position of the frame in the stream.
+type Codec interface {
+	Encode(dst, src []byte, index int64) ([]byte, error)
+	Decode(dst, src []byte, index int64) ([]byte, error)
+}
+
+// chain applies codecs one after another.
+type chain []Codec
+
+// Chain creates codec applying provided codecs in order on encoding and in reverse order on decoding.
+func Chain(codecs ...Codec) Codec {
+	if len(codecs) == 1 {
+		return codecs[0]
+	}
+
+	return chain(codecs)
+}
+
+func (s chain) Encode(dst, src []byte, index int64) ([]byte, error) {
+	var err error
+
+	for _, codec := range s {
+		src, err = codec.Encode(nil, src, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(dst, src...), nil
+}
+
+func (s chain) Decode(dst, src []byte, index int64) ([]byte, error) {
+	var err error
+
+	for i := len(s) - 1; i >= 0; i-- {
+		src, err = s[i].Decode(nil, src, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(dst, src...), nil
+}
+
+// trailer builds the index written after the last frame.
+func trailer(lengths []uint32, rawSize int64, frameSize int) []byte {
+	res := make([]byte, 4*len(lengths)+trailerSize)
+
+	for i, l := range lengths {
+		binary.BigEndian.PutUint32(res[4*i:], l)
+	}
+
+	tail := res[4*len(lengths):]
+	binary.BigEndian.PutUint64(tail[0:], uint64(rawSize))
+	binary.BigEndian.PutUint32(tail[8:], uint32(frameSize))
+	binary.BigEndian.PutUint32(tail[12:], uint32(len(lengths)))
+	copy(tail[16:], magic[:])
+
+	return res
+}
@@ -0,0 +1,200 @@
+package frame
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+)
+
+// Reader provides seekable access to raw data of a framed stream.
+// Only the frame containing current offset is read and decoded.
+type Reader struct {
+	src   io.ReadSeeker
+	codec Codec
+
+	loaded    bool
+	size      int64
+	frameSize int64
+	offsets   []int64 // Offsets of frames in the source, the last one points to the trailer.
+
+	pos    int64
+	srcPos int64
+	frame  int
+	enc    []byte
+	buf    []byte
+}
+
+// NewReader creates new Reader. The source is not touched until the first Read or Seek.
+func NewReader(src io.ReadSeeker, codec Codec) *Reader {
+	return &Reader{
+		src:    src,
+		codec:  codec,
+		srcPos: -1,
+		frame:  -1,
+	}
+}
+
+// Size returns size of raw data.
+func (s *Reader) Size() (int64, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	return s.size, nil
+}
+
+func (s *Reader) Read(p []byte) (int, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+
+	idx := int(s.pos / s.frameSize)
+	if idx != s.frame {
+		if err := s.readFrame(idx); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.buf[s.pos-int64(idx)*s.frameSize:])
+	s.pos += int64(n)
+
+	return n, nil
+}
+
+func (s *Reader) Seek(offset int64, whence int) (int64, error) {
+	if err := s.load(); err != nil {
+		return s.pos, err
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return s.pos, errors.Errorf("unknown whence %d", whence)
+	}
+
+	if offset < 0 {
+		return s.pos, os.ErrInvalid
+	}
+
+	s.pos = offset
+	return s.pos, nil
+}
+
+// Close closes the source if it's closable.
+func (s *Reader) Close() error {
+	if c, ok := s.src.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// readFrame reads and decodes frame with specified index.
+func (s *Reader) readFrame(idx int) error {
+	start, end := s.offsets[idx], s.offsets[idx+1]
+
+	if s.srcPos != start {
+		if _, err := s.src.Seek(start, io.SeekStart); err != nil {
+			s.srcPos = -1
+			return err
+		}
+	}
+
+	if int64(cap(s.enc)) < end-start {
+		s.enc = make([]byte, end-start)
+	}
+	s.enc = s.enc[:end-start]
+
+	if _, err := io.ReadFull(s.src, s.enc); err != nil {
+		s.srcPos = -1
+		return err
+	}
+	s.srcPos = end
+
+	buf, err := s.codec.Decode(s.buf[:0], s.enc, int64(idx))
+	if err != nil {
+		s.frame = -1
+		return err
+	}
+
+	var expected int64
+	if idx == len(s.offsets)-2 {
+		expected = s.size - int64(idx)*s.frameSize
+	} else {
+		expected = s.frameSize
+	}
+
+	if int64(len(buf)) != expected {
+		s.frame = -1
+		return ErrInvalidStream
+	}
+
+	s.buf = buf
+	s.frame = idx
+
+	return nil
+}
+
+// load reads trailer of the stream and builds frame index.
+func (s *Reader) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	end, err := s.src.Seek(-trailerSize, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	tail := make([]byte, trailerSize)
+	if _, err = io.ReadFull(s.src, tail); err != nil {
+		return err
+	}
+
+	if [4]byte(tail[16:20]) != magic {
+		return ErrInvalidStream
+	}
+
+	size := int64(binary.BigEndian.Uint64(tail[0:]))
+	frameSize := int64(binary.BigEndian.Uint32(tail[8:]))
+	count := int64(binary.BigEndian.Uint32(tail[12:]))
+
+	if frameSize < 1 || count*4 > end || (count == 0) != (size == 0) ||
+		(count > 0 && (size <= (count-1)*frameSize || size > count*frameSize)) {
+		return ErrInvalidStream
+	}
+
+	if _, err = s.src.Seek(end-count*4, io.SeekStart); err != nil {
+		return err
+	}
+
+	lengths := make([]byte, count*4)
+	if _, err = io.ReadFull(s.src, lengths); err != nil {
+		return err
+	}
+
+	offsets := make([]int64, count+1)
+	for i := int64(0); i < count; i++ {
+		offsets[i+1] = offsets[i] + int64(binary.BigEndian.Uint32(lengths[4*i:]))
+	}
+
+	if offsets[count] != end-count*4 {
+		return ErrInvalidStream
+	}
+
+	s.size = size
+	s.frameSize = frameSize
+	s.offsets = offsets
+	s.loaded = true
+
+	return nil
+}
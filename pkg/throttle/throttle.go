@@ -0,0 +1,173 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// minBurst is a minimum number of bytes a bucket holds, so slow buckets don't split reads into tiny ones.
+const minBurst = 1024
+
+// Bucket is a token bucket limiting rate of bytes. It's safe for concurrent use,
+// so a bucket shared by several streams limits their total rate. Nil bucket doesn't limit anything.
+type Bucket struct {
+	rate  int64
+	burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket creates bucket passing rate bytes per second, bursts are limited by one second of rate.
+// Returns nil if rate isn't positive.
+func NewBucket(rate int64) *Bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	burst := rate
+	if burst < minBurst {
+		burst = minBurst
+	}
+
+	return &Bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Rate returns rate of the bucket, bytes per second. Zero means unlimited.
+func (s *Bucket) Rate() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.rate
+}
+
+// Wait takes n tokens, waiting until they're available. Tokens are returned if ctx is done while waiting.
+func (s *Bucket) Wait(ctx context.Context, n int) error {
+	if s == nil || n <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+
+	now := time.Now()
+
+	s.tokens += now.Sub(s.last).Seconds() * float64(s.rate)
+	if s.tokens > float64(s.burst) {
+		s.tokens = float64(s.burst)
+	}
+
+	s.last = now
+	s.tokens -= float64(n)
+	deficit := -s.tokens
+
+	s.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / float64(s.rate) * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.tokens += float64(n)
+		s.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// reader limits reading from the source with all its buckets.
+type reader struct {
+	ctx     context.Context
+	src     io.Reader
+	buckets []*Bucket
+	// maxRead limits a single read, so it fits the smallest bucket.
+	maxRead int
+}
+
+// NewReader returns reader limited by the buckets. Nil buckets are ignored, source is returned as is if none left.
+// Waiting is aborted once ctx is done.
+func NewReader(ctx context.Context, src io.Reader, buckets ...*Bucket) io.Reader {
+	res := newReader(ctx, src, buckets)
+	if res == nil {
+		return src
+	}
+
+	return res
+}
+
+// NewReadSeekCloser is the same as NewReader for sources which can be seeked and closed.
+func NewReadSeekCloser(ctx context.Context, src io.ReadSeekCloser, buckets ...*Bucket) io.ReadSeekCloser {
+	res := newReader(ctx, src, buckets)
+	if res == nil {
+		return src
+	}
+
+	return readSeekCloser{reader: res, src: src}
+}
+
+func newReader(ctx context.Context, src io.Reader, buckets []*Bucket) *reader {
+	res := &reader{ctx: ctx, src: src}
+
+	for _, b := range buckets {
+		if b == nil {
+			continue
+		}
+
+		res.buckets = append(res.buckets, b)
+
+		if res.maxRead == 0 || int(b.burst) < res.maxRead {
+			res.maxRead = int(b.burst)
+		}
+	}
+
+	if len(res.buckets) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Read reads from the source, then waits until read bytes are allowed by all buckets.
+func (s *reader) Read(p []byte) (int, error) {
+	if len(p) > s.maxRead {
+		p = p[:s.maxRead]
+	}
+
+	n, err := s.src.Read(p)
+
+	for _, b := range s.buckets {
+		if waitErr := b.Wait(s.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+type readSeekCloser struct {
+	*reader
+	src io.ReadSeekCloser
+}
+
+func (s readSeekCloser) Seek(offset int64, whence int) (int64, error) {
+	return s.src.Seek(offset, whence)
+}
+
+func (s readSeekCloser) Close() error {
+	return s.src.Close()
+}